@@ -7,6 +7,7 @@ package chanio
 import (
 	"io"
 	"net"
+	"sync"
 
 	"github.com/npat-efault/gohacks/errors"
 )
@@ -27,15 +28,32 @@ type Buffer struct {
 	Err  error
 }
 
+// IsTerminal is the default predicate used by Rx to decide if a
+// read error is terminal (that is, if reading should stop after
+// it). It returns true for io.EOF, for errors that test true with
+// errors.IsClosed(), and for errors that do not test true with
+// errors.IsTemporary().
+func IsTerminal(err error) bool {
+	if err == io.EOF || errors.IsClosed(err) {
+		return true
+	}
+	return !errors.IsTemporary(err)
+}
+
 // Rx provides a channel interface for reading (receiving) data from
 // an io.ReadCloser.
 type Rx struct {
+	mu       sync.Mutex
+	closed   bool
 	r        io.ReadCloser
 	maxPckSz int
 	pool     Pool
 	pbuf     []byte
+	term     func(error) bool
+	f        Framer
 	cbuf     chan Buffer
 	quit     chan struct{}
+	done     chan struct{}
 }
 
 // NewRx creates and returns an Rx receiver. It spawns a goroutine
@@ -47,43 +65,96 @@ type Rx struct {
 // buffers returned by the pool *must* have capacity >= "maxPckSz". If
 // "pool" is nil, or if pool.Get() returns nil, new buffers are
 // allocated by the Rx.
+//
+// Reading stops after a terminal error (see IsTerminal): The error is
+// delivered (once) through the Rx.Buf() channel, and then the
+// channel is closed. Reading continues after non-terminal errors.
 func NewRx(r io.ReadCloser, maxPckSz int, pool Pool) *Rx {
+	return NewRxTerminal(r, maxPckSz, pool, IsTerminal)
+}
+
+// NewRxTerminal is the same as NewRx, but uses predicate function
+// term, instead of IsTerminal, to decide if a read error is terminal.
+func NewRxTerminal(r io.ReadCloser, maxPckSz int, pool Pool,
+	term func(error) bool) *Rx {
+	return newRx(r, maxPckSz, pool, term, nil)
+}
+
+// NewRxFramed creates and returns a framed Rx receiver. Instead of
+// delivering the data returned by each Read call, a framed receiver
+// uses framer f (see Framer) to split the data read into messages,
+// and delivers exactly one complete message per Buffer. Framing
+// errors (e.g. ErrFrameTooLarge) are delivered through the Buffer.Err
+// field, with Buffer.Data set to nil. The Read method of the
+// io.ReadCloser is called with buffers of length == "maxPckSz". If
+// the "pool" argument is not nil, its Get() method is called to
+// supply the buffers for the messages delivered; if a buffer returned
+// by the pool is not large enough for a message, a new one is
+// allocated. Predicate function term is used to decide if a read
+// error is terminal; if it is nil, IsTerminal is used. After a
+// terminal error, the data received before the error are decoded
+// (and delivered) before the error itself.
+func NewRxFramed(r io.ReadCloser, f Framer, maxPckSz int, pool Pool,
+	term func(error) bool) *Rx {
+	if term == nil {
+		term = IsTerminal
+	}
+	return newRx(r, maxPckSz, pool, term, f)
+}
+
+func newRx(r io.ReadCloser, maxPckSz int, pool Pool,
+	term func(error) bool, f Framer) *Rx {
 	rx := &Rx{}
 	rx.r = r
 	rx.maxPckSz = maxPckSz
 	rx.pool = pool
-	if pool == nil {
+	if pool == nil && f == nil {
 		rx.pbuf = make([]byte, maxPckSz)
 	}
+	rx.term = term
+	rx.f = f
 	rx.cbuf = make(chan Buffer)
 	rx.quit = make(chan struct{})
-	go rx.run()
+	rx.done = make(chan struct{})
+	if f != nil {
+		go rx.runFramed(rx.cbuf)
+	} else {
+		go rx.run(rx.cbuf)
+	}
 	return rx
 }
 
 // Buf returns the channel where reader-data (and any detected errors)
-// can be received from.
+// can be received from. The channel is closed after a terminal error
+// is delivered.
 func (rx *Rx) Buf() <-chan Buffer {
+	rx.mu.Lock()
+	defer rx.mu.Unlock()
 	return rx.cbuf
 }
 
 // Close terminates the operation of the receiver and releases the
 // respective goroutine. Subsequent reads from the Rx.Buf() channel
-// will always block. Close can be called multiple times (it will
-// return ErrClosed after the first). It is *not* safe to call Close
-// concurently from multiple goroutines.
+// will always block. Close can be called multiple times, and
+// concurrently from multiple goroutines; it returns ErrClosed after
+// the first call.
 func (rx *Rx) Close() error {
-	if rx.cbuf == nil {
+	rx.mu.Lock()
+	if rx.closed {
+		rx.mu.Unlock()
 		return ErrClosed
 	}
+	rx.closed = true
 	err := rx.r.Close()
-	rx.quit <- struct{}{}
-	close(rx.quit) // Concurent calls to rx.Close may panic
+	close(rx.quit)
 	rx.cbuf = nil
+	rx.mu.Unlock()
+	<-rx.done
 	return err
 }
 
-func (rx *Rx) run() {
+func (rx *Rx) run(cbuf chan Buffer) {
+	defer close(rx.done)
 	var err error
 	var p []byte
 	for {
@@ -106,7 +177,79 @@ func (rx *Rx) run() {
 		select {
 		case <-rx.quit:
 			return
-		case rx.cbuf <- Buffer{p, err}:
+		case cbuf <- Buffer{p, err}:
+		}
+		if err != nil && rx.term(err) {
+			close(cbuf)
+			return
+		}
+	}
+}
+
+// send sends b on channel cbuf, unless the receiver is closed. It
+// returns false if the receiver is closed.
+func (rx *Rx) send(cbuf chan Buffer, b Buffer) bool {
+	select {
+	case <-rx.quit:
+		return false
+	case cbuf <- b:
+		return true
+	}
+}
+
+// msgBuf returns a copy of msg, in a buffer from the pool, if
+// possible.
+func (rx *Rx) msgBuf(msg []byte) []byte {
+	if msg == nil {
+		return nil
+	}
+	var p []byte
+	if rx.pool != nil {
+		p = rx.pool.Get()
+		if p != nil && cap(p) < len(msg) {
+			rx.pool.Put(p)
+			p = nil
+		}
+	}
+	if p == nil {
+		p = make([]byte, len(msg))
+	}
+	p = p[:len(msg)]
+	copy(p, msg)
+	return p
+}
+
+func (rx *Rx) runFramed(cbuf chan Buffer) {
+	defer close(rx.done)
+	var data []byte // data read, not yet consumed by the framer
+	var rerr error  // terminal read error
+	rbuf := make([]byte, rx.maxPckSz)
+	for {
+		adv, msg, err := rx.f.Decode(data, rerr != nil)
+		data = data[adv:]
+		if msg != nil || err != nil {
+			if !rx.send(cbuf, Buffer{rx.msgBuf(msg), err}) {
+				return
+			}
+			continue
+		}
+		if adv > 0 {
+			continue
+		}
+		if rerr != nil {
+			if rx.send(cbuf, Buffer{nil, rerr}) {
+				close(cbuf)
+			}
+			return
+		}
+		n, err := rx.r.Read(rbuf)
+		data = append(data, rbuf[:n]...)
+		if err != nil {
+			if rx.term(err) {
+				rerr = err
+			} else if !rx.send(cbuf, Buffer{nil, err}) {
+				return
+			}
 		}
 	}
 }
@@ -115,7 +258,9 @@ func (rx *Rx) run() {
 // channel. It is sent by Tx to indicate an error durring the
 // transmission of the last buffer. N is used by the writer to
 // indicate the number of bytes transmitted before the error (if
-// applicable) and Err to indicate the error.
+// applicable) and Err to indicate the error. For framed transmitters
+// (see NewTxFramed), N is the number of bytes of the encoded frame
+// transmitted.
 type Result struct {
 	N   int
 	Err error
@@ -124,11 +269,20 @@ type Result struct {
 // Tx provides a channel interface for writing (sending) data to an
 // io.WriteCloser.
 type Tx struct {
-	w     io.WriteCloser
-	pool  Pool
-	cdata chan []byte
-	res   chan Result
-	quit  chan struct{}
+	mu       sync.Mutex
+	closed   bool
+	draining bool
+	wclosed  bool
+	werr     error
+	w        io.WriteCloser
+	pool     Pool
+	f        Framer
+	fbuf     []byte
+	cdata    chan []byte
+	res      chan Result
+	quit     chan struct{}
+	drain    chan struct{}
+	done     chan struct{}
 }
 
 // NewTx creates and returns a Tx transmitter. It spawns a goroutine
@@ -137,18 +291,35 @@ type Tx struct {
 // argument is not nil, after the data are transmitter the buffer is
 // returned to the pool by calling pool.Put().
 func NewTx(w io.WriteCloser, pool Pool) *Tx {
+	return NewTxFramed(w, nil, pool)
+}
+
+// NewTxFramed creates and returns a framed Tx transmitter. Each
+// buffer sent on the Tx.Data() channel is encoded as a frame, using
+// framer f (see Framer), and the frame is written to the
+// io.WriteCloser with a single Write call. Encoding errors
+// (e.g. ErrFrameTooLarge) are reported through the Result.Err field,
+// in which case nothing is written. If f is nil, buffers are written
+// as they are (as with NewTx).
+func NewTxFramed(w io.WriteCloser, f Framer, pool Pool) *Tx {
 	tx := &Tx{}
 	tx.w = w
 	tx.pool = pool
+	tx.f = f
 	tx.cdata = make(chan []byte)
 	tx.res = make(chan Result)
 	tx.quit = make(chan struct{})
-	go tx.run()
+	tx.drain = make(chan struct{})
+	tx.done = make(chan struct{})
+	go tx.run(tx.cdata, tx.res)
 	return tx
 }
 
-// Data returns the channel where data can be sent to.
+// Data returns the channel where data can be sent to. After
+// Tx.CloseWrite (or Tx.Drain) it returns nil.
 func (tx *Tx) Data() chan<- []byte {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
 	return tx.cdata
 }
 
@@ -157,46 +328,119 @@ func (tx *Tx) Data() chan<- []byte {
 // transmitted, Rx.Tx sends a Result structure on this channel
 // reporting whether the transmission was succesful, or not. Rx.Tx
 // will not accept new data until the user has received this result.
+// After Tx.CloseWrite (or Tx.Drain), the channel is closed once all
+// results have been received and the writer has been closed.
 func (tx *Tx) Res() <-chan Result {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
 	return tx.res
 }
 
+// closeW closes the writer, once, and returns the error returned by
+// its Close method. Must be called with tx.mu held.
+func (tx *Tx) closeW() error {
+	if !tx.wclosed {
+		tx.wclosed = true
+		tx.werr = tx.w.Close()
+	}
+	return tx.werr
+}
+
 // Close immediately terminates the operation of the transmitter and
 // releases the respective goroutine. Subsequent writes to the
 // Tx.Data() channel or reads from the Tx.Res() channel will always
-// block. Close can be called multiple times (it will return ErrClosed
-// after the first). It is *not* safe to call Close concurently from
-// multiple goroutines.
+// block. Close can be called multiple times, and concurrently from
+// multiple goroutines; it returns ErrClosed after the first call. It
+// can also be called to abort a Tx.CloseWrite or Tx.Drain in
+// progress.
 func (tx *Tx) Close() error {
-	if tx.cdata == nil {
+	tx.mu.Lock()
+	if tx.closed {
+		tx.mu.Unlock()
 		return ErrClosed
 	}
-	err := tx.w.Close()
-	tx.quit <- struct{}{}
-	close(tx.quit) // Concur. calls to tx.Close/Drain may panic
+	tx.closed = true
+	err := tx.closeW()
+	close(tx.quit)
 	tx.cdata = nil
 	tx.res = nil
+	tx.mu.Unlock()
+	<-tx.done
 	return err
 }
 
-func (tx *Tx) run() {
+// CloseWrite stops the transmitter from accepting new data, without
+// aborting the transmission of data already accepted. The result for
+// the data already accepted (if any) is sent, as usual, on the
+// Tx.Res() channel. Once it has been received, the writer is closed,
+// and the Tx.Res() channel is closed. CloseWrite does not wait for
+// any of this to happen (see Tx.Drain). It returns ErrClosed if
+// Tx.Close, Tx.CloseWrite, or Tx.Drain has already been called.
+func (tx *Tx) CloseWrite() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.closed || tx.draining {
+		return ErrClosed
+	}
+	tx.draining = true
+	close(tx.drain)
+	tx.cdata = nil
+	return nil
+}
+
+// Drain is like Tx.CloseWrite, but waits until the results for the
+// data already accepted have been received (by another goroutine),
+// and the writer has been closed. It returns the error returned by
+// the writer's Close method, or ErrClosed if Tx.Close, Tx.CloseWrite,
+// or Tx.Drain has already been called.
+func (tx *Tx) Drain() error {
+	if err := tx.CloseWrite(); err != nil {
+		return err
+	}
+	<-tx.done
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.werr
+}
+
+// write writes p, encoded as a frame if the transmitter is framed.
+func (tx *Tx) write(p []byte) (int, error) {
+	if tx.f == nil {
+		return tx.w.Write(p)
+	}
+	var err error
+	tx.fbuf, err = tx.f.Encode(tx.fbuf[:0], p)
+	if err != nil {
+		return 0, err
+	}
+	return tx.w.Write(tx.fbuf)
+}
+
+func (tx *Tx) run(cdata chan []byte, res chan Result) {
+	defer close(tx.done)
 	for {
 		var err error
 		var n int
 
 		// wait for data
 		select {
-		case p := <-tx.cdata:
-			n, err = tx.w.Write(p)
+		case p := <-cdata:
+			n, err = tx.write(p)
 			if tx.pool != nil {
 				tx.pool.Put(p)
 			}
+		case <-tx.drain:
+			tx.mu.Lock()
+			tx.closeW()
+			tx.mu.Unlock()
+			close(res)
+			return
 		case <-tx.quit:
 			return
 		}
 		// send back result
 		select {
-		case tx.res <- Result{n, err}:
+		case res <- Result{n, err}:
 		case <-tx.quit:
 			return
 		}
@@ -213,9 +457,12 @@ type Connection struct {
 
 // Lx provides a channel interface for accepting network connections.
 type Lx struct {
-	l     net.Listener
-	cconn chan Connection
-	quit  chan struct{}
+	mu     sync.Mutex
+	closed bool
+	l      net.Listener
+	cconn  chan Connection
+	quit   chan struct{}
+	done   chan struct{}
 }
 
 // NewLx creates and returns a new Lx listener. It spawns a goroutine
@@ -226,32 +473,40 @@ func NewLx(l net.Listener) *Lx {
 	lx.l = l
 	lx.cconn = make(chan Connection)
 	lx.quit = make(chan struct{})
-	go lx.run()
+	lx.done = make(chan struct{})
+	go lx.run(lx.cconn)
 	return lx
 }
 
 // Conn returns the channel where connections can be received from.
 func (lx *Lx) Conn() <-chan Connection {
+	lx.mu.Lock()
+	defer lx.mu.Unlock()
 	return lx.cconn
 }
 
 // Close terminates the operation of the listener and releases the
 // respective goroutine. Subsequent reads from the Lx.Conn() channel
-// will always block. Close can be called multiple times (it will
-// return ErrClosed after the first). It is *not* safe to call Close
-// concurently from multiple goroutines.
+// will always block. Close can be called multiple times, and
+// concurrently from multiple goroutines; it returns ErrClosed after
+// the first call.
 func (lx *Lx) Close() error {
-	if lx.cconn == nil {
+	lx.mu.Lock()
+	if lx.closed {
+		lx.mu.Unlock()
 		return ErrClosed
 	}
+	lx.closed = true
 	err := lx.l.Close()
-	lx.quit <- struct{}{}
-	close(lx.quit) // Concurent calls to lx.Close may panic
+	close(lx.quit)
 	lx.cconn = nil
+	lx.mu.Unlock()
+	<-lx.done
 	return err
 }
 
-func (lx *Lx) run() {
+func (lx *Lx) run(cconn chan Connection) {
+	defer close(lx.done)
 	var err error
 	var c net.Conn
 	for {
@@ -259,12 +514,7 @@ func (lx *Lx) run() {
 		select {
 		case <-lx.quit:
 			return
-		case lx.cconn <- Connection{c, err}:
+		case cconn <- Connection{c, err}:
 		}
 	}
 }
-
-// NOTE(npat): In order to make Close() concurency-safe (and allow the
-// same chanio.Rx/Lx/Tx to be used, safely, by multiple goroutines),
-// all that's required is to protect the Close() methods and the
-// Buf()/Data()/Res()/Conn() accessors with a mutex.
@@ -3,9 +3,11 @@ package chanio
 import (
 	"bytes"
 	"io"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/npat-efault/gohacks/errors"
 	"github.com/npat-efault/gohacks/pool"
 	"github.com/npat-efault/gohacks/testutil"
 )
@@ -65,6 +67,66 @@ func TestRx(t *testing.T) {
 	doTestRx(t, data, 4, p)
 }
 
+func doTestRxTerminal(t *testing.T, rx *Rx, last error) int {
+	var n int
+	var err error
+	for p := range rx.Buf() {
+		if err != nil {
+			t.Fatalf("Buffer after terminal error %v: %v", err, p)
+		}
+		n += len(p.Data)
+		if p.Err != nil && p.Err != testutil.ErrTemporary {
+			err = p.Err
+		}
+	}
+	if err != last {
+		t.Fatalf("Bad terminal error: %v", err)
+	}
+	if e := rx.Close(); e != nil {
+		t.Fatal("Close:", e)
+	}
+	if e := rx.Close(); e != ErrClosed {
+		t.Fatal("Close again:", e)
+	}
+	return n
+}
+
+func TestRxTerminal(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	// EOF, after temporary errors
+	r := testutil.NewFakeIO()
+	r.ErrEvery = 3
+	r.FillBytes(data)
+	if n := doTestRxTerminal(t, NewRx(r, 2, nil), io.EOF); n != len(data) {
+		t.Fatalf("Read %d bytes", n)
+	}
+
+	// Permanent error
+	r = testutil.NewFakeIO()
+	r.ErrAfter = 2
+	r.FillBytes(data)
+	if n := doTestRxTerminal(t, NewRx(r, 2, nil), testutil.ErrPermanent); n != 4 {
+		t.Fatalf("Read %d bytes", n)
+	}
+
+	// Custom predicate: Temporary errors are terminal
+	r = testutil.NewFakeIO()
+	r.ErrEvery = 3
+	r.FillBytes(data)
+	rx := NewRxTerminal(r, 2, nil, func(error) bool { return true })
+	p := <-rx.Buf()
+	p = <-rx.Buf()
+	p = <-rx.Buf()
+	if p.Err != testutil.ErrTemporary {
+		t.Fatal("Bad error:", p.Err)
+	}
+	if _, ok := <-rx.Buf(); ok {
+		t.Fatal("Channel not closed")
+	}
+	rx.Close()
+}
+
 func doTestTx(t *testing.T, data []byte, sz int, errEvery int, pl Pool) {
 	w := testutil.NewFakeIO()
 	w.ErrEvery = errEvery
@@ -278,3 +340,74 @@ loop:
 		t.Fatal("Bad data!")
 	}
 }
+
+func TestCloseConcurrent(t *testing.T) {
+	const n = 10
+	r := testutil.NewFakeIO()
+	r.FillBytes(make([]byte, 1024))
+	rx := NewRx(r, 2, nil)
+	w := testutil.NewFakeIO()
+	tx := NewTx(w, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen:", err)
+	}
+	lx := NewLx(l)
+
+	for _, c := range []io.Closer{rx, tx, lx} {
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			go func() { errs <- c.Close() }()
+		}
+		var nok int
+		for i := 0; i < n; i++ {
+			if e := <-errs; e != ErrClosed {
+				nok++
+			}
+		}
+		if nok != 1 {
+			t.Fatalf("%T: %d Close calls not returning ErrClosed", c, nok)
+		}
+	}
+	if rx.Buf() != nil || tx.Data() != nil || tx.Res() != nil ||
+		lx.Conn() != nil {
+		t.Fatal("Channels not nil after Close")
+	}
+}
+
+func TestTxDrain(t *testing.T) {
+	w := testutil.NewFakeIO()
+	w.Delay = 50 * time.Millisecond
+	tx := NewTx(w, nil)
+	data := []byte{0, 1, 2, 3}
+	tx.Data() <- data
+	res := tx.Res()
+	drained := make(chan error)
+	go func() { drained <- tx.Drain() }()
+	r, ok := <-res
+	if !ok || r.Err != nil || r.N != len(data) {
+		t.Fatalf("Bad result: %v, %v", r, ok)
+	}
+	if _, ok := <-res; ok {
+		t.Fatal("Res channel not closed")
+	}
+	if e := <-drained; e != nil {
+		t.Fatal("Drain:", e)
+	}
+	if !bytes.Equal(data, w.Bytes()) {
+		t.Fatal("Bad data!")
+	}
+	if _, e := w.Write(data); !errors.IsClosed(e) {
+		t.Fatal("Writer not closed:", e)
+	}
+	if tx.Data() != nil {
+		t.Fatal("Data channel not nil after Drain")
+	}
+	if e := tx.Drain(); e != ErrClosed {
+		t.Fatal("Drain again:", e)
+	}
+	if e := tx.CloseWrite(); e != ErrClosed {
+		t.Fatal("CloseWrite again:", e)
+	}
+	tx.Close()
+}
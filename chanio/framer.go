@@ -0,0 +1,328 @@
+package chanio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// Framing errors. They are reported by framers (see Framer) through
+// the Buffer.Err field (for Rx) and the Result.Err field (for Tx).
+// They have Temporary() == true, and test true with predicate
+// function errors.IsTemporary(); therefore they are not terminal for
+// Rx (see IsTerminal): the receiver skips the offending frame and
+// continues with the next one.
+var (
+	ErrFrameTooLarge  = errors.ErrNL(errors.ErrTemporary, "Frame too large")
+	ErrFrameInvalid   = errors.ErrNL(errors.ErrTemporary, "Invalid frame")
+	ErrFrameTruncated = errors.ErrNL(errors.ErrTemporary, "Truncated frame")
+)
+
+// Framer splits a stream of bytes into messages (frames), and encodes
+// messages into frames. Framers are used by framed receivers (see
+// NewRxFramed) and transmitters (see NewTxFramed). A framer may keep
+// state between calls (e.g. while skipping an oversized frame),
+// therefore a Framer value must not be shared by multiple receivers.
+type Framer interface {
+	// Decode is called with the data received so far (and not
+	// yet consumed). It returns the number of bytes consumed
+	// (advance), and the next message (msg), if one is
+	// complete. If no message is complete, and no bytes can be
+	// consumed, it returns 0, nil, nil, and is called again when
+	// more data are available. If the data are invalid, it
+	// returns a non-nil error (and advances past the offending
+	// frame, if possible). Argument atEOF is true if no more data
+	// will be received. The message returned may refer to the data
+	// passed to Decode, and is only valid until the next call.
+	Decode(data []byte, atEOF bool) (advance int, msg []byte, err error)
+	// Encode appends the frame for message msg to dst and returns
+	// the extended slice. If msg cannot be encoded (e.g. it is
+	// too large), it returns a non-nil error.
+	Encode(dst, msg []byte) ([]byte, error)
+}
+
+// delimFramer implements framers where frames are terminated by a
+// delimiter byte.
+type delimFramer struct {
+	delim   byte
+	max     int  // max message size
+	rawMax  int  // max encoded frame size (excluding delimiter)
+	partial bool // deliver unterminated frame at EOF
+	skip    bool // skip empty frames
+	dec     func(frame []byte) ([]byte, error)
+	enc     func(dst, msg []byte) ([]byte, error)
+	discard bool // discarding frame up to next delimiter
+}
+
+func (f *delimFramer) Decode(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexByte(data, f.delim)
+	if f.discard {
+		if i < 0 {
+			return len(data), nil, nil
+		}
+		f.discard = false
+		return i + 1, nil, nil
+	}
+	adv := i + 1
+	if i < 0 {
+		if len(data) > f.rawMax {
+			f.discard = !atEOF
+			return len(data), nil, ErrFrameTooLarge
+		}
+		if !atEOF || len(data) == 0 {
+			return 0, nil, nil
+		}
+		if !f.partial {
+			return len(data), nil, ErrFrameTruncated
+		}
+		i, adv = len(data), len(data)
+	}
+	frame := data[:i]
+	if len(frame) == 0 && f.skip {
+		return adv, nil, nil
+	}
+	if len(frame) > f.rawMax {
+		return adv, nil, ErrFrameTooLarge
+	}
+	msg, err := f.dec(frame)
+	if err != nil {
+		return adv, nil, err
+	}
+	if len(msg) > f.max {
+		return adv, nil, ErrFrameTooLarge
+	}
+	return adv, msg, nil
+}
+
+func (f *delimFramer) Encode(dst, msg []byte) ([]byte, error) {
+	if len(msg) > f.max {
+		return dst, ErrFrameTooLarge
+	}
+	return f.enc(dst, msg)
+}
+
+// NewDelimFramer returns a framer for messages terminated by the
+// delimiter byte delim. Messages are delivered without the delimiter,
+// and cannot contain it (Encode rejects them with ErrFrameInvalid).
+// Messages larger than max bytes are rejected with ErrFrameTooLarge.
+// At EOF, unterminated data (if any) are delivered as the last
+// message.
+func NewDelimFramer(delim byte, max int) Framer {
+	f := &delimFramer{delim: delim, max: max, rawMax: max, partial: true}
+	f.dec = func(frame []byte) ([]byte, error) { return frame, nil }
+	f.enc = func(dst, msg []byte) ([]byte, error) {
+		if bytes.IndexByte(msg, delim) >= 0 {
+			return dst, ErrFrameInvalid
+		}
+		return append(append(dst, msg...), delim), nil
+	}
+	return f
+}
+
+// NewLineFramer returns a framer for newline-terminated messages
+// (lines). It is the same as NewDelimFramer('\n', max).
+func NewLineFramer(max int) Framer {
+	return NewDelimFramer('\n', max)
+}
+
+// SLIP special characters (RFC 1055)
+const (
+	slipEnd    = 0xc0
+	slipEsc    = 0xdb
+	slipEscEnd = 0xdc
+	slipEscEsc = 0xdd
+)
+
+// NewSLIPFramer returns a framer for SLIP-encoded messages (RFC
+// 1055). Frames are preceded and terminated by the SLIP END
+// character; empty frames are ignored. Messages larger than max bytes
+// (after decoding) are rejected with ErrFrameTooLarge; invalid escape
+// sequences with ErrFrameInvalid.
+func NewSLIPFramer(max int) Framer {
+	f := &delimFramer{delim: slipEnd, max: max, rawMax: 2 * max, skip: true}
+	f.dec = func(frame []byte) ([]byte, error) {
+		msg := make([]byte, 0, len(frame))
+		for i := 0; i < len(frame); i++ {
+			c := frame[i]
+			if c == slipEsc {
+				if i++; i == len(frame) {
+					return nil, ErrFrameInvalid
+				}
+				switch frame[i] {
+				case slipEscEnd:
+					c = slipEnd
+				case slipEscEsc:
+					c = slipEsc
+				default:
+					return nil, ErrFrameInvalid
+				}
+			}
+			msg = append(msg, c)
+		}
+		return msg, nil
+	}
+	f.enc = func(dst, msg []byte) ([]byte, error) {
+		dst = append(dst, slipEnd)
+		for _, c := range msg {
+			switch c {
+			case slipEnd:
+				dst = append(dst, slipEsc, slipEscEnd)
+			case slipEsc:
+				dst = append(dst, slipEsc, slipEscEsc)
+			default:
+				dst = append(dst, c)
+			}
+		}
+		return append(dst, slipEnd), nil
+	}
+	return f
+}
+
+// NewCOBSFramer returns a framer for COBS-encoded messages
+// (Consistent Overhead Byte Stuffing). Frames are terminated by a
+// zero byte; empty frames are ignored. Messages larger than max bytes
+// (after decoding) are rejected with ErrFrameTooLarge; invalid
+// encodings with ErrFrameInvalid.
+func NewCOBSFramer(max int) Framer {
+	f := &delimFramer{delim: 0, max: max, rawMax: max + max/254 + 1, skip: true}
+	f.dec = func(frame []byte) ([]byte, error) {
+		msg := make([]byte, 0, len(frame))
+		for i := 0; i < len(frame); {
+			n := int(frame[i])
+			if i+n > len(frame) {
+				return nil, ErrFrameInvalid
+			}
+			msg = append(msg, frame[i+1:i+n]...)
+			i += n
+			if n < 0xff && i < len(frame) {
+				msg = append(msg, 0)
+			}
+		}
+		return msg, nil
+	}
+	f.enc = func(dst, msg []byte) ([]byte, error) {
+		ci := len(dst) // position of code byte
+		dst = append(dst, 0)
+		for _, c := range msg {
+			if c != 0 {
+				dst = append(dst, c)
+			}
+			if c == 0 || len(dst)-ci == 0xff {
+				dst[ci] = byte(len(dst) - ci)
+				ci = len(dst)
+				dst = append(dst, 0)
+			}
+		}
+		dst[ci] = byte(len(dst) - ci)
+		return append(dst, 0), nil
+	}
+	return f
+}
+
+// lenFramer implements framers where frames are prefixed by their
+// length.
+type lenFramer struct {
+	max  int
+	hdr  func(data []byte) (n uint64, sz int) // decode length prefix
+	put  func(dst []byte, n int) []byte       // encode length prefix
+	skip uint64                               // bytes to skip
+}
+
+func (f *lenFramer) Decode(data []byte, atEOF bool) (int, []byte, error) {
+	if f.skip > 0 {
+		n := len(data)
+		if uint64(n) > f.skip {
+			n = int(f.skip)
+		}
+		f.skip -= uint64(n)
+		return n, nil, nil
+	}
+	n, sz := f.hdr(data)
+	if sz < 0 {
+		// No way to re-synchronize; drop everything.
+		return len(data), nil, ErrFrameInvalid
+	}
+	if sz > 0 && n > uint64(f.max) {
+		if atEOF {
+			// Drop the payload received, if any.
+			if avail := uint64(len(data) - sz); n > avail {
+				n = avail
+			}
+			return sz + int(n), nil, ErrFrameTooLarge
+		}
+		f.skip = n
+		return sz, nil, ErrFrameTooLarge
+	}
+	if sz == 0 || uint64(len(data)-sz) < n {
+		if atEOF && len(data) > 0 {
+			return len(data), nil, ErrFrameTruncated
+		}
+		return 0, nil, nil
+	}
+	end := sz + int(n)
+	return end, data[sz:end], nil
+}
+
+func (f *lenFramer) Encode(dst, msg []byte) ([]byte, error) {
+	if len(msg) > f.max {
+		return dst, ErrFrameTooLarge
+	}
+	return append(f.put(dst, len(msg)), msg...), nil
+}
+
+// NewVarintFramer returns a framer for messages prefixed by their
+// length, encoded as an unsigned varint (see encoding/binary).
+// Messages larger than max bytes are rejected with ErrFrameTooLarge;
+// invalid (overflowing) length prefixes with ErrFrameInvalid.
+func NewVarintFramer(max int) Framer {
+	return &lenFramer{
+		max: max,
+		hdr: binary.Uvarint,
+		put: func(dst []byte, n int) []byte {
+			var b [binary.MaxVarintLen64]byte
+			return append(dst, b[:binary.PutUvarint(b[:], uint64(n))]...)
+		},
+	}
+}
+
+// NewLengthFramer returns a framer for messages prefixed by their
+// length, encoded as a size-bytes big-endian unsigned integer. Size
+// must be 2 or 4, otherwise NewLengthFramer panics. Messages larger
+// than max bytes (or than the largest length that can be encoded)
+// are rejected with ErrFrameTooLarge.
+func NewLengthFramer(size int, max int) Framer {
+	f := &lenFramer{max: max}
+	switch size {
+	case 2:
+		if f.max > 0xffff {
+			f.max = 0xffff
+		}
+		f.hdr = func(data []byte) (uint64, int) {
+			if len(data) < 2 {
+				return 0, 0
+			}
+			return uint64(binary.BigEndian.Uint16(data)), 2
+		}
+		f.put = func(dst []byte, n int) []byte {
+			return append(dst, byte(n>>8), byte(n))
+		}
+	case 4:
+		if m := uint64(math.MaxUint32); uint64(f.max) > m {
+			f.max = int(m)
+		}
+		f.hdr = func(data []byte) (uint64, int) {
+			if len(data) < 4 {
+				return 0, 0
+			}
+			return uint64(binary.BigEndian.Uint32(data)), 4
+		}
+		f.put = func(dst []byte, n int) []byte {
+			return append(dst, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+	default:
+		panic("chanio.NewLengthFramer: size must be 2 or 4")
+	}
+	return f
+}
@@ -0,0 +1,232 @@
+package chanio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/npat-efault/gohacks/pool"
+	"github.com/npat-efault/gohacks/testutil"
+)
+
+var framers = []struct {
+	name string
+	new  func(max int) Framer
+}{
+	{"delim", func(max int) Framer { return NewDelimFramer('|', max) }},
+	{"line", NewLineFramer},
+	{"varint", NewVarintFramer},
+	{"len2", func(max int) Framer { return NewLengthFramer(2, max) }},
+	{"len4", func(max int) Framer { return NewLengthFramer(4, max) }},
+	{"slip", NewSLIPFramer},
+	{"cobs", NewCOBSFramer},
+}
+
+// testMsgs returns messages that can be encoded by all framers.
+func testMsgs() [][]byte {
+	long := make([]byte, 600)
+	for i := range long {
+		long[i] = byte(i%250) + 1
+	}
+	zeros := make([]byte, 300)
+	return [][]byte{
+		[]byte("hello"),
+		{},
+		{0, 1, 0, 0, 2},
+		{slipEnd, slipEsc, slipEscEnd, slipEscEsc, slipEnd},
+		long,
+		long[:254],
+		long[:255],
+		zeros,
+		[]byte("world"),
+	}
+}
+
+func encodeAll(t *testing.T, f Framer, msgs [][]byte) []byte {
+	var b []byte
+	var err error
+	for i, m := range msgs {
+		b, err = f.Encode(b, m)
+		if err != nil {
+			t.Fatalf("Encode %d: %v", i, err)
+		}
+	}
+	return b
+}
+
+func TestFramerRoundTrip(t *testing.T) {
+	for _, fr := range framers {
+		msgs := testMsgs()
+		if fr.name == "delim" || fr.name == "line" {
+			// Messages must not contain the delimiter
+			msgs = [][]byte{[]byte("hello"), {}, {0, 1, 2}, []byte("world")}
+		} else if fr.name == "slip" {
+			// Empty SLIP frames are ignored
+			msgs = append(msgs[:1], msgs[2:]...)
+		}
+		f := fr.new(1024)
+		enc := encodeAll(t, f, msgs)
+		// Feed the encoded data to Decode one byte at a time.
+		var data [][]byte
+		var buf []byte
+		for i := 0; i <= len(enc); i++ {
+			atEOF := i == len(enc)
+			if !atEOF {
+				buf = append(buf, enc[i])
+			}
+			for {
+				adv, msg, err := f.Decode(buf, atEOF)
+				if err != nil {
+					t.Fatalf("%s: Decode: %v", fr.name, err)
+				}
+				buf = buf[adv:]
+				if msg != nil {
+					data = append(data, append([]byte{}, msg...))
+				} else if adv == 0 {
+					break
+				}
+			}
+		}
+		if len(data) != len(msgs) {
+			t.Fatalf("%s: Decoded %d msgs, expected %d", fr.name,
+				len(data), len(msgs))
+		}
+		for i := range msgs {
+			if !bytes.Equal(msgs[i], data[i]) {
+				t.Fatalf("%s: msg %d: %v != %v", fr.name, i, data[i], msgs[i])
+			}
+		}
+	}
+}
+
+func TestFramerErrors(t *testing.T) {
+	for _, fr := range framers {
+		f := fr.new(8)
+		if _, err := f.Encode(nil, make([]byte, 9)); err != ErrFrameTooLarge {
+			t.Fatalf("%s: Encode large: %v", fr.name, err)
+		}
+	}
+	if _, err := NewLineFramer(10).Encode(nil, []byte("a\nb")); err != ErrFrameInvalid {
+		t.Fatal("Encode with delimiter:", err)
+	}
+	if _, err := NewLengthFramer(2, 1<<20).Encode(nil, make([]byte, 1<<16)); err != ErrFrameTooLarge {
+		t.Fatal("Encode len2 large:", err)
+	}
+
+	// Invalid SLIP escape
+	_, _, err := NewSLIPFramer(8).Decode([]byte{slipEnd, 1, slipEsc, 2, slipEnd}, false)
+	if err != nil {
+		t.Fatal("SLIP leading END:", err)
+	}
+	f := NewSLIPFramer(8)
+	_, _, err = f.Decode([]byte{1, slipEsc, 2, slipEnd}, false)
+	if err != ErrFrameInvalid {
+		t.Fatal("SLIP bad escape:", err)
+	}
+	// Invalid COBS code
+	_, _, err = NewCOBSFramer(8).Decode([]byte{5, 1, 0}, false)
+	if err != ErrFrameInvalid {
+		t.Fatal("COBS bad code:", err)
+	}
+	// Truncated
+	_, _, err = NewVarintFramer(8).Decode([]byte{3, 1}, true)
+	if err != ErrFrameTruncated {
+		t.Fatal("Varint truncated:", err)
+	}
+	_, _, err = NewCOBSFramer(8).Decode([]byte{3, 1}, true)
+	if err != ErrFrameTruncated {
+		t.Fatal("COBS truncated:", err)
+	}
+	// Too large, at EOF: the payload must be dropped
+	f = NewLengthFramer(2, 4)
+	data := []byte{0, 6, 0, 1, 'a', 'b', 'c', 'd'}
+	adv, msg, err := f.Decode(data, true)
+	if adv != len(data) || msg != nil || err != ErrFrameTooLarge {
+		t.Fatal("Len2 large at EOF:", adv, msg, err)
+	}
+	adv, msg, err = f.Decode(data[adv:], true)
+	if adv != 0 || msg != nil || err != nil {
+		t.Fatal("Len2 after large at EOF:", adv, msg, err)
+	}
+	data = []byte{0, 6, 'a', 'b', 'c', 'd', 'e', 'f', 0, 1, 'g'}
+	adv, msg, err = f.Decode(data, true)
+	if adv != 8 || msg != nil || err != ErrFrameTooLarge {
+		t.Fatal("Len2 large at EOF:", adv, msg, err)
+	}
+	adv, msg, err = f.Decode(data[adv:], true)
+	if adv != 3 || string(msg) != "g" || err != nil {
+		t.Fatal("Len2 after large at EOF:", adv, msg, err)
+	}
+	adv, msg, err = NewLineFramer(8).Decode([]byte("abc"), true)
+	if adv != 3 || string(msg) != "abc" || err != nil {
+		t.Fatal("Line at EOF:", adv, msg, err)
+	}
+}
+
+// TestRxFramed checks that framed receivers reassemble messages from
+// small reads, and skip oversized frames.
+func TestRxFramed(t *testing.T) {
+	p := pool.NewByteSlice(4, nil)
+	for _, fr := range framers {
+		msgs := [][]byte{
+			[]byte("first"),
+			[]byte("too large message"),
+			[]byte("second"),
+			[]byte("third"),
+		}
+		enc := encodeAll(t, fr.new(1024), msgs)
+		r := testutil.NewFakeIO()
+		r.Limit = 3
+		r.ErrEvery = 4
+		r.FillBytes(enc)
+		rx := NewRxFramed(r, fr.new(10), 4, p, nil)
+		var got []string
+		var nlarge int
+		for b := range rx.Buf() {
+			switch b.Err {
+			case nil:
+				got = append(got, string(b.Data))
+			case ErrFrameTooLarge:
+				nlarge++
+			case testutil.ErrTemporary, io.EOF:
+			default:
+				t.Fatalf("%s: Bad error: %v", fr.name, b.Err)
+			}
+		}
+		if nlarge != 1 {
+			t.Fatalf("%s: %d ErrFrameTooLarge", fr.name, nlarge)
+		}
+		if len(got) != 3 || got[0] != "first" || got[1] != "second" ||
+			got[2] != "third" {
+			t.Fatalf("%s: Bad messages: %q", fr.name, got)
+		}
+		if err := rx.Close(); err != nil {
+			t.Fatalf("%s: Close: %v", fr.name, err)
+		}
+	}
+}
+
+func TestTxFramed(t *testing.T) {
+	for _, fr := range framers {
+		w := testutil.NewFakeIO()
+		tx := NewTxFramed(w, fr.new(10), nil)
+		msgs := [][]byte{[]byte("first"), []byte("too large message"),
+			[]byte("second")}
+		for i, m := range msgs {
+			tx.Data() <- m
+			r := <-tx.Res()
+			if i == 1 {
+				if r.Err != ErrFrameTooLarge || r.N != 0 {
+					t.Fatalf("%s: Bad result: %v", fr.name, r)
+				}
+			} else if r.Err != nil {
+				t.Fatalf("%s: Bad result: %v", fr.name, r)
+			}
+		}
+		tx.Close()
+		exp := encodeAll(t, fr.new(10), [][]byte{msgs[0], msgs[2]})
+		if !bytes.Equal(exp, w.Bytes()) {
+			t.Fatalf("%s: Bad data: %v", fr.name, w.Bytes())
+		}
+	}
+}
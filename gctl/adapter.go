@@ -0,0 +1,229 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// ErrGroup is a drop-in replacement for errgroup.Group (from
+// golang.org/x/sync/errgroup), backed by its embedded Gcx. Its
+// goroutines are goroutines of the Gcx, so they are reported by
+// Snapshot, can monitor Gcx.ChKill, and are subject to the context's
+// kill policy. As with errgroup, the first goroutine to fail kills
+// the context, and its error is returned by ErrGroup.Wait.
+//
+// The zero ErrGroup is valid, has no limit on the number of active
+// goroutines, and does not cancel on error (other than by killing
+// the Gcx). Since, as with errgroup, goroutines can be added to the
+// group until ErrGroup.Wait is called, the Gcx does not terminate
+// before that. Unlike an errgroup.Group, an ErrGroup cannot be reused
+// after ErrGroup.Wait returns (unless reset, see Gcx.Reset).
+type ErrGroup struct {
+	Gcx
+	kp     keeper
+	mu     sync.Mutex
+	sem    chan struct{}
+	cancel context.CancelFunc // set by WithContext
+}
+
+// WithContext returns a new ErrGroup and an associated Context
+// derived from ctx. The derived Context is canceled the first time a
+// goroutine in the group fails (or, more generally, when the group's
+// Gcx is killed), or the first time Wait returns, whichever occurs
+// first.
+func WithContext(ctx context.Context) (*ErrGroup, context.Context) {
+	g := &ErrGroup{}
+	ctx, g.cancel = context.WithCancel(ctx)
+	g.OnKill(func(error) { g.cancel() })
+	return g, ctx
+}
+
+// SetLimit limits the number of active goroutines in the group to at
+// most n. A negative value indicates no limit. Like with errgroup,
+// the limit must not be modified while goroutines in the group are
+// active.
+func (g *ErrGroup) SetLimit(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.sem) != 0 {
+		panic(fmt.Sprintf("ErrGroup.SetLimit: modify limit while "+
+			"%v goroutines in the group are still active", len(g.sem)))
+	}
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+func (g *ErrGroup) limit() chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sem
+}
+
+// Go calls function f in a new goroutine of the group. It blocks
+// until the new goroutine can be added without the number of active
+// goroutines exceeding the configured limit. If f returns an error,
+// it is handled like the exit status of any goroutine of the Gcx (by
+// default, it kills the Gcx and becomes its exit status).
+func (g *ErrGroup) Go(f func() error) {
+	loc := caller(0)
+	sem := g.limit()
+	if sem != nil {
+		sem <- struct{}{}
+	}
+	g.start(loc, sem, f)
+}
+
+// TryGo calls function f in a new goroutine of the group only if the
+// number of active goroutines is currently below the configured
+// limit. It returns true if the goroutine was started.
+func (g *ErrGroup) TryGo(f func() error) bool {
+	loc := caller(0)
+	sem := g.limit()
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(loc, sem, f)
+	return true
+}
+
+func (g *ErrGroup) start(loc errors.Location, sem chan struct{},
+	f func() error) {
+	g.kp.keep(&g.Gcx)
+	g.spawnAt(loc, "", nil, func() error {
+		if sem != nil {
+			defer func() { <-sem }()
+		}
+		return f()
+	})
+}
+
+// Wait waits for all goroutines in the group to return, and then
+// returns the first non-nil error (if any) from them: The exit status
+// of the Gcx. If no goroutine was ever started, it returns nil.
+func (g *ErrGroup) Wait() error {
+	g.kp.release(&g.Gcx)
+	err := g.Gcx.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	if err == ErrGcxEmpty {
+		return nil
+	}
+	return err
+}
+
+// WaitGroup is a drop-in replacement for sync.WaitGroup, backed by
+// its embedded Gcx. The goroutines started with WaitGroup.Go are
+// goroutines of the Gcx, so they are reported by Snapshot, and can
+// monitor Gcx.ChKill. Goroutines accounted for with WaitGroup.Add and
+// WaitGroup.Done, instead, keep the Gcx alive, but are not reported
+// individually.
+//
+// The zero WaitGroup is valid. The Gcx does not terminate before
+// WaitGroup.Wait is called. Unlike a sync.WaitGroup, a WaitGroup
+// cannot be reused once Wait returns (unless reset, see Gcx.Reset).
+type WaitGroup struct {
+	Gcx
+	kp keeper
+	n  int32
+}
+
+// Add adds delta, which may be negative, to the WaitGroup counter.
+// If the counter becomes negative, Add panics.
+func (wg *WaitGroup) Add(delta int) {
+	for ; delta > 0; delta-- {
+		wg.kp.keep(&wg.Gcx)
+		atomic.AddInt32(&wg.n, 1)
+		wg.hold()
+	}
+	for ; delta < 0; delta++ {
+		wg.Done()
+	}
+}
+
+// Done decrements the WaitGroup counter by one.
+func (wg *WaitGroup) Done() {
+	if atomic.AddInt32(&wg.n, -1) < 0 {
+		panic("WaitGroup.Done: negative WaitGroup counter")
+	}
+	wg.exit(nil, nil, nil)
+}
+
+// Go calls function f in a new goroutine of the Gcx, and accounts for
+// it in the WaitGroup.
+func (wg *WaitGroup) Go(f func()) {
+	wg.kp.keep(&wg.Gcx)
+	wg.spawn(1, "", nil, func() error {
+		f()
+		return nil
+	})
+}
+
+// Wait blocks until all goroutines started with WaitGroup.Go have
+// returned, and the counter maintained by WaitGroup.Add and
+// WaitGroup.Done is zero.
+func (wg *WaitGroup) Wait() {
+	wg.kp.release(&wg.Gcx)
+	wg.Gcx.Wait()
+}
+
+// hold keeps context c alive, as if a goroutine was started in it,
+// until a matching call to c.exit(nil, nil, nil). If c is dead it
+// panics, like Gcx.Go.
+func (c *Gcx) hold() {
+	var kw kwork
+	defer func() { kw.do() }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ngort == -1 {
+		panic("Gcx.Go: Gcx context is dead")
+	}
+	if c.kill == nil {
+		kw = c.start()
+	}
+	c.ngort++
+}
+
+// keeper keeps a context alive, as if it had an additional
+// goroutine, from the first call to keeper.keep until the first call
+// to keeper.release.
+type keeper struct {
+	mu       sync.Mutex
+	held     bool
+	released bool
+}
+
+func (k *keeper) keep(c *Gcx) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.held && !k.released {
+		c.hold()
+		k.held = true
+	}
+}
+
+func (k *keeper) release(c *Gcx) {
+	k.mu.Lock()
+	held := k.held
+	k.held, k.released = false, true
+	k.mu.Unlock()
+	if held {
+		c.exit(nil, nil, nil)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestErrGroup(t *testing.T) {
+	var g ErrGroup
+	if e := g.Wait(); e != nil {
+		t.Fatalf("Wait empty: %v", e)
+	}
+
+	errFail := errors.New("fail")
+	g1, ctx := WithContext(context.Background())
+	g1.Go(func() error {
+		<-ctx.Done()
+		return nil
+	})
+	g1.Go(func() error {
+		time.Sleep(10 * time.Millisecond)
+		return errFail
+	})
+	if e := g1.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("Context not canceled")
+	}
+
+	g2, ctx := WithContext(context.Background())
+	g2.Go(func() error { return nil })
+	if e := g2.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("Context not canceled after Wait")
+	}
+
+	g3, ctx := WithContext(context.Background())
+	if e := g3.Wait(); e != nil {
+		t.Fatalf("Wait empty: %v", e)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("Context not canceled after empty Wait")
+	}
+}
+
+func TestErrGroupLimit(t *testing.T) {
+	var g ErrGroup
+	g.SetLimit(2)
+	var active, max int32
+	f := func() error {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return nil
+	}
+	for i := 0; i < 10; i++ {
+		g.Go(f)
+	}
+	if g.TryGo(f) && g.TryGo(f) && g.TryGo(f) {
+		t.Fatal("TryGo: limit exceeded")
+	}
+	if e := g.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+	if max != 2 {
+		t.Fatalf("Max active: %d", max)
+	}
+}
+
+func TestWaitGroup(t *testing.T) {
+	var wg WaitGroup
+	wg.Wait() // Empty
+	var n int32
+	for i := 0; i < 5; i++ {
+		wg.Go(func() {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&n, 1)
+		})
+	}
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&n, 1)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	if n != 7 {
+		t.Fatalf("Wait returned early: %d", n)
+	}
+
+	var wg1 WaitGroup
+	wg1.Add(1)
+	defer func() {
+		if x := recover(); x == nil {
+			t.Fatal("No panic on negative counter")
+		}
+	}()
+	wg1.Add(-2)
+}
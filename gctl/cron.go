@@ -0,0 +1,172 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// cron is a Schedule specified by a cron expression. Each field is a
+// bitmask of the allowed values.
+type cron struct {
+	min, hour, dom, month, dow uint64
+	domStar, dowStar           bool
+}
+
+var cronFields = [...]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron-like schedule expression and returns the
+// respective Schedule. The expression has five space-separated
+// fields: minute (0-59), hour (0-23), day of month (1-31), month
+// (1-12), and day of week (0-7, both 0 and 7 are Sunday). Each field
+// is a comma-separated list of "*", single values, or ranges
+// ("a-b"), optionally followed by a step ("*/15", "1-30/2"); a single
+// value followed by a step ("5/15") is the same as the range from the
+// value to the maximum ("5-59/15"). As with cron, if both the day of
+// month and the day of week are restricted (do not start with "*"),
+// a day matches if either matches. The descriptors
+// @yearly, @annually, @monthly, @weekly, @daily, @midnight and
+// @hourly are also accepted, as is "@every <duration>" (e.g. "@every
+// 1h30m"), which is the same as an Interval. Times are matched in the
+// location of the times passed to Schedule.Next.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, errors.Errf(0, "Bad cron interval: %q", expr)
+		}
+		return Interval(d), nil
+	}
+	if e, ok := cronDescriptors[expr]; ok {
+		expr = e
+	}
+	fs := strings.Fields(expr)
+	if len(fs) != len(cronFields) {
+		return nil, errors.Errf(0, "Bad cron expression: %q", expr)
+	}
+	var bits [len(cronFields)]uint64
+	for i, f := range fs {
+		var err error
+		bits[i], err = parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Bad cron %s field", cronFields[i].name)
+		}
+	}
+	c := &cron{
+		min: bits[0], hour: bits[1], dom: bits[2], month: bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fs[2], "*"),
+		dowStar: strings.HasPrefix(fs[4], "*"),
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, p := range strings.Split(f, ",") {
+		step, stepped := 1, false
+		if i := strings.IndexByte(p, '/'); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(p[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Errf(0, "Bad step: %q", p)
+			}
+			p, stepped = p[:i], true
+		}
+		lo, hi := min, max
+		if p != "*" {
+			r := strings.SplitN(p, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(r[0]); err != nil {
+				return 0, errors.Errf(0, "Bad value: %q", p)
+			}
+			hi = lo
+			if len(r) == 2 {
+				if hi, err = strconv.Atoi(r[1]); err != nil {
+					return 0, errors.Errf(0, "Bad value: %q", p)
+				}
+			} else if stepped {
+				// As with Vixie cron, "a/n" is "a-max/n"
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, errors.Errf(0, "Value out of range: %q", p)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cron) dayMatch(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cronYears limits the search for the next matching time, so that
+// expressions that never match (e.g. "0 0 30 2 *") terminate.
+const cronYears = 5
+
+// Next returns the first time after t that matches the cron
+// expression, or the zero time if there is no such time.
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(cronYears, 0, 0)
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.min&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import "time"
+
+// SetDeadline arranges for context c to be killed at time t, if it
+// has not terminated by then. A context killed this way has exit
+// status ErrDeadline (unless one of its goroutines has already
+// failed with a different status). Calling SetDeadline again
+// replaces the previous deadline; a zero t removes it. If the context
+// is empty, SetDeadline returns ErrGcxEmpty. If the context is dead,
+// it does nothing.
+func (c *Gcx) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill == nil {
+		return ErrGcxEmpty
+	}
+	if c.ngort == -1 {
+		return nil
+	}
+	c.stopTimer()
+	if t.IsZero() {
+		return nil
+	}
+	gen := c.tgen
+	c.timer = time.AfterFunc(t.Sub(time.Now()), func() {
+		c.expire(gen)
+	})
+	return nil
+}
+
+// KillAfter is the same as calling Gcx.SetDeadline with
+// time.Now().Add(d).
+func (c *Gcx) KillAfter(d time.Duration) error {
+	return c.SetDeadline(time.Now().Add(d))
+}
+
+// stopTimer cancels the deadline timer of c, if any. Must be called
+// with c.mu held.
+func (c *Gcx) stopTimer() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.tgen++
+}
+
+// expire is called when the deadline timer of generation gen fires.
+func (c *Gcx) expire(gen int) {
+	c.mu.Lock()
+	if gen != c.tgen || c.kill == nil || c.ngort == -1 {
+		c.mu.Unlock()
+		return
+	}
+	c.timer = nil
+	kw := c.fail(ErrDeadline, ErrDeadline)
+	c.mu.Unlock()
+	kw.do()
+}
+
+// WaitTimeout is like Gcx.Wait, but waits for no longer than d. If
+// the context has not terminated by then, it returns
+// ErrWaitTimeout. The context itself is not affected.
+func (c *Gcx) WaitTimeout(d time.Duration) error {
+	return c.WaitUntil(time.Now().Add(d))
+}
+
+// WaitUntil is like Gcx.Wait, but waits no later than time t. If the
+// context has not terminated by then, it returns ErrWaitTimeout. The
+// context itself is not affected.
+func (c *Gcx) WaitUntil(t time.Time) error {
+	c.mu.Lock()
+	if c.kill == nil {
+		c.mu.Unlock()
+		return ErrGcxEmpty
+	}
+	c.mu.Unlock()
+	// Prefer an already terminated context to an expired timer.
+	select {
+	case <-c.dead:
+		return c.status
+	default:
+	}
+	tm := time.NewTimer(t.Sub(time.Now()))
+	defer tm.Stop()
+	select {
+	case <-c.dead:
+		return c.status
+	case <-tm.C:
+		return ErrWaitTimeout
+	}
+}
+
+// WaitTimeout is like Group.Wait, but waits for no longer than d. If
+// no context in the group terminates by then, it returns nil,
+// ErrWaitTimeout.
+func (g *Group) WaitTimeout(d time.Duration) (c *Gcx, xs error) {
+	return g.WaitUntil(time.Now().Add(d))
+}
+
+// WaitUntil is like Group.Wait, but waits no later than time t. If
+// no context in the group terminates by then, it returns nil,
+// ErrWaitTimeout.
+func (g *Group) WaitUntil(t time.Time) (c *Gcx, xs error) {
+	if g.Count() == 0 {
+		return nil, nil
+	}
+	if c = g.pop(); c != nil {
+		return c, c.Wait()
+	}
+	tm := time.NewTimer(t.Sub(time.Now()))
+	defer tm.Stop()
+	for {
+		select {
+		case <-g.ready:
+		case <-tm.C:
+			return nil, ErrWaitTimeout
+		}
+		if g.Count() == 0 {
+			return nil, nil
+		}
+		if c = g.pop(); c != nil {
+			return c, c.Wait()
+		}
+	}
+}
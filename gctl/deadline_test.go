@@ -0,0 +1,79 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestDeadline(t *testing.T) {
+	var c Gcx
+	if e := c.KillAfter(time.Second); e != ErrGcxEmpty {
+		t.Fatalf("Gcx.KillAfter: %v", e)
+	}
+	c.Go(waitKill(&c))
+	c.KillAfter(20 * time.Millisecond)
+	e := c.Wait()
+	if e != ErrDeadline || !errors.IsTimeout(e) {
+		t.Fatalf("Gcx.Wait: %v", e)
+	}
+
+	// Removed deadline
+	var c2 Gcx
+	c2.Go(func() error {
+		select {
+		case <-c2.ChKill():
+			return ErrKilled
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+	c2.KillAfter(20 * time.Millisecond)
+	c2.SetDeadline(time.Time{})
+	if e := c2.Wait(); e != nil {
+		t.Fatalf("Gcx.Wait: %v", e)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	var c Gcx
+	c.Go(waitKill(&c))
+	e := c.WaitTimeout(20 * time.Millisecond)
+	if e != ErrWaitTimeout || !errors.IsTimeout(e) {
+		t.Fatalf("Gcx.WaitTimeout: %v", e)
+	}
+	select {
+	case <-c.kill:
+		t.Fatal("WaitTimeout killed context")
+	default:
+	}
+	c.Kill()
+	if e := c.WaitTimeout(time.Second); e != ErrKilled {
+		t.Fatalf("Gcx.WaitTimeout: %v", e)
+	}
+}
+
+func TestGroupWaitTimeout(t *testing.T) {
+	var g Group
+	var c Gcx
+	if x, xs := g.WaitTimeout(time.Second); x != nil || xs != nil {
+		t.Fatalf("Group.WaitTimeout: x = %p, xs = %v", x, xs)
+	}
+	c.SetGroup(&g)
+	c.Go(waitKill(&c))
+	x, xs := g.WaitTimeout(20 * time.Millisecond)
+	if x != nil || xs != ErrWaitTimeout {
+		t.Fatalf("Group.WaitTimeout: x = %p, xs = %v", x, xs)
+	}
+	c.Kill()
+	x, xs = g.WaitUntil(time.Now().Add(time.Second))
+	if x != &c || xs != ErrKilled {
+		t.Fatalf("Group.WaitUntil: x = %p, xs = %v", x, xs)
+	}
+}
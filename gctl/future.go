@@ -0,0 +1,175 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package gctl
+
+import "reflect"
+
+// Future is the result of a function run, as a goroutine of a
+// context, by Spawn.
+type Future[T any] struct {
+	c    *Gcx
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Spawn runs function f as a goroutine in context c, and returns a
+// Future for its result. The error returned by f is also the
+// goroutine's exit status, and affects c like that of any other
+// goroutine: By default it kills c (see Gcx.SetPolicy, and
+// Gcx.GoPolicy, for alternatives). If c is dead, Spawn panics, like
+// Gcx.Go.
+func Spawn[T any](c *Gcx, f func() (T, error)) *Future[T] {
+	loc := caller(0)
+	ft := &Future[T]{c: c, done: make(chan struct{})}
+	c.spawnAt(loc, "future", nil, func() error {
+		defer close(ft.done)
+		ft.val, ft.err = f()
+		return ft.err
+	})
+	return ft
+}
+
+// Done returns a channel that is closed when the function run by the
+// future returns.
+func (ft *Future[T]) Done() <-chan struct{} {
+	return ft.done
+}
+
+// Await waits for the function run by the future to return, and
+// returns its results. If the future's context is killed before
+// that, Await returns the zero value of T and ErrKilled.
+func (ft *Future[T]) Await() (T, error) {
+	select {
+	case <-ft.done:
+		return ft.val, ft.err
+	default:
+	}
+	select {
+	case <-ft.done:
+		return ft.val, ft.err
+	case <-ft.c.ChKill():
+		var zero T
+		return zero, ErrKilled
+	}
+}
+
+// futuresGcx returns the context of futures fs, and panics if they
+// do not all belong to the same context.
+func futuresGcx[T any](fn string, fs []*Future[T]) *Gcx {
+	if len(fs) == 0 {
+		panic("gctl." + fn + ": no futures")
+	}
+	c := fs[0].c
+	for _, ft := range fs[1:] {
+		if ft.c != c {
+			panic("gctl." + fn + ": futures of different contexts")
+		}
+	}
+	return c
+}
+
+// awaitNext waits for any of the futures fs that are not nil to
+// complete, and returns its index. If the context c is killed
+// before that, it returns -1.
+func awaitNext[T any](c *Gcx, fs []*Future[T]) int {
+	cases := make([]reflect.SelectCase, 0, len(fs)+1)
+	idx := make([]int, 0, len(fs))
+	for i, ft := range fs {
+		if ft == nil {
+			continue
+		}
+		select {
+		case <-ft.done:
+			return i
+		default:
+		}
+		cases = append(cases, reflect.SelectCase{
+			Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ft.done)})
+		idx = append(idx, i)
+	}
+	cases = append(cases, reflect.SelectCase{
+		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ChKill())})
+	i, _, _ := reflect.Select(cases)
+	if i == len(idx) {
+		return -1
+	}
+	return idx[i]
+}
+
+// All waits for all futures fs to complete, and returns their values,
+// in the order of fs. If any of them fails, All returns immediately
+// with the first error (in order of completion). If the context is
+// killed before all futures complete, All returns ErrKilled. All
+// futures must belong to the same context. With no futures, All
+// returns nil, nil.
+func All[T any](fs ...*Future[T]) ([]T, error) {
+	if len(fs) == 0 {
+		return nil, nil
+	}
+	c := futuresGcx("All", fs)
+	pend := append([]*Future[T](nil), fs...)
+	for n := len(pend); n > 0; n-- {
+		i := awaitNext(c, pend)
+		if i < 0 {
+			return nil, ErrKilled
+		}
+		if err := pend[i].err; err != nil {
+			return nil, err
+		}
+		pend[i] = nil
+	}
+	vs := make([]T, len(fs))
+	for i, ft := range fs {
+		vs[i] = ft.val
+	}
+	return vs, nil
+}
+
+// Any waits for the first of the futures fs to complete successfully
+// and returns its value. If all of them fail, Any returns the error
+// of the last one to complete. Since, by default, a goroutine failure
+// kills the context, Any is mostly useful with a kill policy that
+// ignores, or only records, the futures' errors (see
+// Gcx.SetPolicy). If the context is killed before a future completes
+// successfully, Any returns ErrKilled. All futures must belong to the
+// same context. Any panics if called with no futures.
+func Any[T any](fs ...*Future[T]) (T, error) {
+	c := futuresGcx("Any", fs)
+	pend := append([]*Future[T](nil), fs...)
+	var err error
+	for n := len(pend); n > 0; n-- {
+		i := awaitNext(c, pend)
+		if i < 0 {
+			err = ErrKilled
+			break
+		}
+		if err = pend[i].err; err == nil {
+			return pend[i].val, nil
+		}
+		pend[i] = nil
+	}
+	var zero T
+	return zero, err
+}
+
+// First waits for the first of the futures fs to complete, and
+// returns its results, whether successful or not. If the context is
+// killed before any of the futures completes, First returns
+// ErrKilled. All futures must belong to the same context. First
+// panics if called with no futures.
+func First[T any](fs ...*Future[T]) (T, error) {
+	c := futuresGcx("First", fs)
+	i := awaitNext(c, fs)
+	if i < 0 {
+		var zero T
+		return zero, ErrKilled
+	}
+	return fs[i].val, fs[i].err
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package gctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func sleepFor[T any](d time.Duration, v T, err error) func() (T, error) {
+	return func() (T, error) {
+		time.Sleep(d)
+		return v, err
+	}
+}
+
+func TestFutureAwait(t *testing.T) {
+	var c Gcx
+	ft := Spawn(&c, sleepFor(10*time.Millisecond, 42, nil))
+	select {
+	case <-ft.Done():
+		t.Fatal("Done too early")
+	default:
+	}
+	if v, err := ft.Await(); v != 42 || err != nil {
+		t.Fatalf("Await: %v, %v", v, err)
+	}
+	<-ft.Done()
+	if e := c.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+
+	var c1 Gcx
+	ft = Spawn(&c1, func() (int, error) {
+		<-c1.ChKill()
+		time.Sleep(10 * time.Millisecond)
+		return 1, nil
+	})
+	c1.Kill()
+	if v, err := ft.Await(); v != 0 || err != ErrKilled {
+		t.Fatalf("Await killed: %v, %v", v, err)
+	}
+	c1.Wait()
+}
+
+func TestFutureAll(t *testing.T) {
+	var c Gcx
+	vs, err := All(
+		Spawn(&c, sleepFor(20*time.Millisecond, "a", nil)),
+		Spawn(&c, sleepFor(10*time.Millisecond, "b", nil)),
+		Spawn(&c, sleepFor(0, "c", nil)))
+	if err != nil || len(vs) != 3 || vs[0] != "a" || vs[1] != "b" || vs[2] != "c" {
+		t.Fatalf("All: %v, %v", vs, err)
+	}
+	c.Wait()
+
+	errFail := errors.New("fail")
+	var c1 Gcx
+	vs, err = All(
+		Spawn(&c1, func() (string, error) {
+			<-c1.ChKill()
+			return "", ErrKilled
+		}),
+		Spawn(&c1, sleepFor(10*time.Millisecond, "", errFail)))
+	if err != errFail || vs != nil {
+		t.Fatalf("All failed: %v, %v", vs, err)
+	}
+	if e := c1.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+}
+
+func TestFutureAnyFirst(t *testing.T) {
+	errFail := errors.New("fail")
+	var c Gcx
+	c.SetPolicy(func(error) Action { return PolicyIgnore })
+	fs := []*Future[int]{
+		Spawn(&c, sleepFor(0, 0, errFail)),
+		Spawn(&c, sleepFor(20*time.Millisecond, 2, nil)),
+		Spawn(&c, sleepFor(40*time.Millisecond, 3, nil)),
+	}
+	if v, err := First(fs...); v != 0 || err != errFail {
+		t.Fatalf("First: %v, %v", v, err)
+	}
+	if v, err := Any(fs...); v != 2 || err != nil {
+		t.Fatalf("Any: %v, %v", v, err)
+	}
+	if v, err := Any(fs[0]); err != errFail {
+		t.Fatalf("Any failed: %v, %v", v, err)
+	}
+	c.Wait()
+
+	var c1 Gcx
+	ft := Spawn(&c1, func() (int, error) {
+		<-c1.ChKill()
+		return 1, nil
+	})
+	time.AfterFunc(10*time.Millisecond, func() { c1.Kill() })
+	if _, err := First(ft); err != ErrKilled {
+		t.Fatalf("First killed: %v", err)
+	}
+	c1.Wait()
+}
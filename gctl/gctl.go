@@ -8,6 +8,7 @@ package gctl
 
 import (
 	"sync"
+	"time"
 
 	"github.com/npat-efault/gohacks/errors"
 )
@@ -16,8 +17,35 @@ var (
 	ErrGcxNotEmpty = errors.New("Gcx context not empty")
 	ErrGcxEmpty    = errors.New("Gcx context is empty")
 	ErrKilled      = errors.New("Gcx context killed")
+	// ErrParentKilled is the kill reason (see Gcx.Reason) of a
+	// context killed because its parent was killed.
+	ErrParentKilled = errors.New("Gcx parent context killed")
+	// ErrDeadline is the exit status of a context killed because
+	// its deadline expired. It tests true with errors.IsTimeout().
+	ErrDeadline = errors.ErrNL(errors.ErrTimeout, "Gcx deadline exceeded")
+	// ErrWaitTimeout is returned by the WaitTimeout / WaitUntil
+	// methods if the wait times-out. It tests true with
+	// errors.IsTimeout().
+	ErrWaitTimeout = errors.ErrNL(errors.ErrTimeout, "Gcx wait timed out")
 )
 
+// FailError is the kill reason (see Gcx.Reason) of a context killed
+// because one of its goroutines, or one of its child contexts,
+// failed.
+type FailError struct {
+	Err   error           // Exit status of the goroutine or child
+	Child bool            // True if a child context failed
+	Name  string          // Name of the goroutine, if not Child
+	Loc   errors.Location // Where the goroutine was started, if tracked
+}
+
+func (e *FailError) Error() string {
+	if e.Child {
+		return "Gcx child context failed: " + e.Err.Error()
+	}
+	return "Gcx goroutine failed: " + e.Err.Error()
+}
+
 // Gcx is a type that represents a goroutine context ("gcx", or
 // "context"). A goroutine context is used to manage one or more
 // related goroutines performing a certain task. A Gcx structure
@@ -44,16 +72,46 @@ var (
 // context, closes the termination channel signaling the goroutines to
 // exit.
 //
+// For graceful shutdown, a context also has a drain channel
+// (Gcx.ChDrain), closed by Gcx.Drain, that asks goroutines to finish
+// their work in progress before exiting. See Gcx.Shutdown.
+//
 // Method Gcx.Wait, called only from outside the context, waits until
 // the context terminates, and returns its exit status.
+//
+// Contexts can be nested, forming trees of related contexts; see
+// Gcx.SetParent.
 type Gcx struct {
 	mu       sync.Mutex
 	kill     chan struct{} // close for termination request
 	dead     chan struct{} // close when context dead
 	ngort    int           // # of goroutines, -1: context dead
 	signaled bool          // kill closed?
+	drain    chan struct{} // close for drain request
+	draining bool          // drain closed?
+	phase    Phase         // shutdown phase
 	status   error         // context exit status
+	failed   bool          // status set by a killing error
+	reason   error         // why the context was killed
 	group    *Group
+	parent   *Gcx
+	policy   ChildPolicy          // how failures propagate to parent
+	kids     map[*Gcx]struct{}    // active child contexts
+	timer    *time.Timer          // deadline timer
+	tgen     int                  // deadline timer generation
+	id       uint64               // unique id, assigned on start
+	pid      uint64               // id of parent, assigned on start
+	born     time.Time            // time started
+	tracked  bool                 // started with tracking enabled
+	gors     map[*GoInfo]struct{} // running goroutines
+	done     []GoInfo             // recently finished goroutines
+	onKill   []func(xs error)     // hooks run when killed
+	onDead   []func(xs error)     // hooks run when dead
+	exiting  bool                 // running OnDead hooks
+	rstReq   bool                 // Reset called from OnDead hook
+	obs      Observer             // observer for this context
+	kpol     KillPolicy           // kill policy for goroutine errors
+	sched    Scheduler            // controls goroutine execution, if set
 }
 
 // GxcZero is the zero (empty) value for a Gcx goroutine context. See
@@ -89,7 +147,8 @@ func (c *Gcx) ChKill() <-chan struct{} {
 //
 // If a goroutine in c exits with a non-nil non-ErrKilled status, then
 // the cancelation channel for c (Gcx.ChKill()) is closed, signaling
-// all other goroutines in c to terminate.
+// all other goroutines in c to terminate. This can be changed by
+// setting a kill policy for the context (see Gcx.SetPolicy).
 //
 // Normally, once a context c has run and terminated (its last
 // goroutine has exited) it becomes "dead" and you cannot start it
@@ -97,81 +156,333 @@ func (c *Gcx) ChKill() <-chan struct{} {
 //
 // CAVEAT: Nevertheless, if you want to use the same Gcx context
 // structure again, you can, provided that you first reset it by
-// assigning to it the value GcxZero. In order to do so safely, you
-// must make certain that the context is indeed dead and that no-one
-// will subcequently use the same Gcx structure to logically refer to
-// the old context. In any case, it is easier *not* to reuse context
+// calling Gcx.Reset (or by assigning to it the value GcxZero). In
+// order to do so safely, you must make certain that no-one will
+// subcequently use the same Gcx structure to logically refer to the
+// old context. In any case, it is easier *not* to reuse context
 // structures, and in most cases there is no reason to.
 func (c *Gcx) Go(f func() error) {
+	c.spawn(1, "", nil, f)
+}
+
+// GoNamed is the same as Gcx.Go, but also assigns a name to the
+// goroutine. The name is only used for identifying the goroutine in
+// the information returned by Snapshot and the related dump
+// functions.
+func (c *Gcx) GoNamed(name string, f func() error) {
+	c.spawn(1, name, nil, f)
+}
+
+// spawn starts f as a goroutine named name in context c. If pol is
+// not nil, it is used instead of the context's kill policy for the
+// goroutine's exit status. Argument skip is the number of stack
+// frames, above the caller of spawn, to skip when recording the
+// goroutine's start location.
+func (c *Gcx) spawn(skip int, name string, pol KillPolicy, f func() error) {
+	c.spawnAt(caller(skip), name, pol, f)
+}
+
+// spawnAt is like spawn, but the goroutine's start location is given
+// explicitly.
+func (c *Gcx) spawnAt(loc errors.Location, name string, pol KillPolicy,
+	f func() error) {
+	var kw kwork
+	defer func() { kw.do() }()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.ngort == -1 {
 		panic("Gcx.Go: Gcx context is dead")
 	}
 	if c.kill == nil {
-		c.kill = make(chan struct{})
-		c.dead = make(chan struct{})
-		if c.group != nil {
-			c.group.mu.Lock()
-			c.group.n++
-			c.group.mu.Unlock()
-		}
+		kw = c.start()
 	}
 	c.ngort++
+	o, id := c.obs, c.id
+	// Anonymous goroutines of untracked, unobserved contexts
+	// share a record that is never modified.
+	r := &anonGo
+	if c.tracked || o != nil || name != "" {
+		r = &GoInfo{Name: name, Loc: loc, Start: time.Now(),
+			State: GoRunning}
+	}
+	if c.tracked {
+		c.gors[r] = struct{}{}
+	}
+	if o != nil {
+		o.GoStart(id, *r)
+	}
+	var begin, end func()
+	if c.sched != nil {
+		begin, end = c.sched.Spawn(name)
+	}
 	go func(c *Gcx, f func() error) {
+		if begin != nil {
+			begin()
+		}
 		err := f()
-		c.mu.Lock()
-		if c.status == nil || c.status == ErrKilled {
-			if err != nil {
-				c.status = err
-				if !c.signaled {
-					close(c.kill)
-					c.signaled = true
-				}
-			}
+		if o != nil {
+			g := *r
+			g.Err = err
+			o.GoEnd(id, g, time.Since(g.Start))
 		}
-		c.ngort--
-		if c.ngort != 0 {
-			c.mu.Unlock()
-			return
+		c.exit(r, err, pol)
+		if end != nil {
+			end()
 		}
+	}(c, f)
+}
+
+// anonGo is the record of goroutines that need none of their own
+// (see spawnAt).
+var anonGo GoInfo
 
-		// Last goroutine in context.
-		c.ngort = -1 // mark as dead
-		g := c.group
+// start initializes context c, making it active. Must be called with
+// c.mu held. If c starts killed (see Gcx.SetParent) the returned work
+// must be done after c.mu is released.
+func (c *Gcx) start() (kw kwork) {
+	c.kill = make(chan struct{})
+	c.drain = make(chan struct{})
+	c.dead = make(chan struct{})
+	c.register()
+	c.observe()
+	if c.parent != nil {
+		kw = c.parent.adopt(c)
+		if c.sched == nil {
+			c.parent.mu.Lock()
+			c.sched = c.parent.sched
+			c.parent.mu.Unlock()
+		}
+	}
+	if c.group != nil {
+		c.group.join(c)
+	}
+	return kw
+}
+
+// exit is called when a goroutine, or a child context, of c
+// terminates with status err. For goroutines, r is the goroutine's
+// record, and pol the goroutine's kill policy (if nil, the context's
+// policy is used); for child contexts (and pending kill work) r and
+// pol are nil, and a non-nil err always kills c.
+func (c *Gcx) exit(r *GoInfo, err error, pol KillPolicy) {
+	c.mu.Lock()
+	if r != nil {
+		if c.tracked {
+			c.retire(r, err)
+		}
+		if pol == nil {
+			pol = c.kpol
+		}
+	}
+	var kw kwork
+	if err != nil {
+		switch c.action(err, pol) {
+		case PolicyKill:
+			why := &FailError{Err: err, Child: r == nil}
+			if r != nil {
+				why.Name, why.Loc = r.Name, r.Loc
+			}
+			kw = c.fail(err, why)
+		case PolicyRecord:
+			c.record(err)
+		}
+	}
+	c.ngort--
+	if c.ngort != 0 {
 		c.mu.Unlock()
+		kw.do()
+		return
+	}
+
+	// Last goroutine in context.
+	c.ngort = -1 // mark as dead
+	c.stopTimer()
+	c.unregister()
+	if c.parent != nil {
+		c.parent.release(c)
+	}
+	g, p, cpol, xs := c.group, c.parent, c.policy, c.status
+	dead, hooks := c.dead, c.onDead
+	c.onKill, c.onDead = nil, nil
+	c.exiting = true
+	c.mu.Unlock()
+	for _, h := range hooks {
+		h(xs)
+	}
+	c.mu.Lock()
+	c.exiting = false
+	rst := c.rstReq
+	c.mu.Unlock()
+
+	// First close, then notify, in order to allow waiting for an
+	// individual context with Gcx.Wait, even if it belongs to a
+	// group.
+	close(dead)
+	// Don't access c after this (unless reset was requested by a
+	// hook). Context c is dead, and they are allowed to zero-out c.
+	if rst {
+		c.mu.Lock()
+		c.reset()
+		c.mu.Unlock()
+	}
+	if p != nil {
+		p.orphan(xs, cpol)
+	}
+	if g != nil {
+		g.finish(c)
+	}
+}
 
-		// First close, then notify, in order to allow waiting
-		// for an individual context with Gcx.Wait, even if it
-		// belongs to a group.
-		close(c.dead)
-		// Don't access c after this. Context c is dead, and
-		// they are allowed to zero-out c.
-		if g != nil {
-			// This may block until Group.Wait is
-			// called.
-			g.notify <- c
+// record records err as the exit status of c, unless c has already
+// failed, or another error has already been recorded. Must be called
+// with c.mu held.
+func (c *Gcx) record(err error) {
+	if c.status == nil || c.status == ErrKilled {
+		c.status = err
+	}
+}
+
+// fail records err as the exit status of c, unless c has already
+// failed, and signals c to terminate for reason why. Unlike record,
+// it replaces an error that was only recorded: The error that kills
+// the context takes priority. It returns the work that must be done,
+// after c.mu is released, to complete the kill. Must be called with
+// c.mu held.
+func (c *Gcx) fail(err, why error) kwork {
+	if err == ErrKilled {
+		c.record(err)
+	} else if !c.failed {
+		c.status, c.failed = err, true
+	}
+	return c.signal(why)
+}
+
+// kwork is the work that must be done, after the context's mutex is
+// released, to complete killing a context: Kill its child contexts
+// and run its OnKill hooks. While there is pending work, the context
+// is kept alive (as if the work was one of its goroutines).
+type kwork struct {
+	c     *Gcx
+	kids  []*Gcx
+	hooks []func(xs error)
+	xs    error
+}
+
+func (kw kwork) do() {
+	if kw.c == nil {
+		return
+	}
+	for _, k := range kw.kids {
+		k.KillWithReason(ErrParentKilled)
+	}
+	for _, h := range kw.hooks {
+		h(kw.xs)
+	}
+	kw.c.exit(nil, nil, nil)
+}
+
+// signal closes the kill channel of context c, if not already
+// closed, recording why as the reason for the kill, and returns the
+// work that must be done, after c.mu is released, to complete the
+// kill. Must be called with c.mu held.
+func (c *Gcx) signal(why error) (kw kwork) {
+	if c.signaled {
+		return kw
+	}
+	c.signaled = true
+	c.startDrain()
+	if c.ngort == -1 {
+		close(c.kill)
+		return kw
+	}
+	c.reason = why
+	c.phase = PhaseKilled
+	close(c.kill)
+	if len(c.kids) == 0 && len(c.onKill) == 0 {
+		return kw
+	}
+	kw.c = c
+	c.ngort++
+	if len(c.kids) != 0 {
+		kw.kids = make([]*Gcx, 0, len(c.kids))
+		for k := range c.kids {
+			kw.kids = append(kw.kids, k)
 		}
-	}(c, f)
+	}
+	kw.hooks, kw.xs = c.onKill, c.status
+	c.onKill = nil
+	return kw
 }
 
 // Kill signals goroutines in context c to stop by closing the channel
-// returned by Gcx.ChKill. If the context is dead, it does nothing. If
+// returned by Gcx.ChKill. Child contexts of c (see Gcx.SetParent) are
+// killed as well. If the context is dead, it does nothing. If
 // the context is empty, it returns ErrGcxEmpty. It is ok to call
 // Kill from either within or outside the context. It is also ok to
 // call Kill (for the same context) multiple times, or concurrently
-// from multiple goroutines.
+// from multiple goroutines. The reason for the kill (see Gcx.Reason)
+// is ErrKilled.
 func (c *Gcx) Kill() error {
+	return c.KillWithReason(ErrKilled)
+}
+
+// KillWithReason is the same as Gcx.Kill, but records err as the
+// reason for the kill (see Gcx.Reason). If err is nil, ErrKilled is
+// recorded. The reason does not affect the exit status of the
+// context, which is still determined by the statuses of its
+// goroutines. If the context has already been killed, the original
+// reason is kept.
+func (c *Gcx) KillWithReason(err error) error {
+	if err == nil {
+		err = ErrKilled
+	}
+	c.mu.Lock()
+	if c.kill == nil {
+		c.mu.Unlock()
+		return ErrGcxEmpty
+	}
+	kw := c.signal(err)
+	c.mu.Unlock()
+	kw.do()
+	return nil
+}
+
+// Reason returns the reason context c was killed, or nil if it has
+// not been killed (or is empty). The reason is available as soon as
+// the channel returned by Gcx.ChKill is closed, and is kept after the
+// context terminates. It is one of:
+//
+//   - ErrKilled, or the error given to Gcx.KillWithReason, if the
+//     context was killed explicitly.
+//   - ErrDeadline, if the context's deadline expired.
+//   - ErrParentKilled, if the context's parent was killed.
+//   - *FailError, if one of the context's goroutines, or child
+//     contexts, failed.
+//   - The error that also becomes the context's exit status, if the
+//     context was killed by one of the facilities of the package
+//     (e.g. *SignalError, or *StallError).
+func (c *Gcx) Reason() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.reason
+}
+
+// killWith kills context c, like Gcx.Kill does, additionally
+// recording err as its exit status, unless c has already failed, and
+// as the reason for the kill. If c is dead it does nothing.
+func (c *Gcx) killWith(err error) error {
+	c.mu.Lock()
 	if c.kill == nil {
+		c.mu.Unlock()
 		return ErrGcxEmpty
 	}
-	if c.signaled {
+	if c.ngort == -1 {
+		c.mu.Unlock()
 		return nil
 	}
-	c.signaled = true
-	close(c.kill)
+	kw := c.fail(err, err)
+	c.mu.Unlock()
+	kw.do()
 	return nil
 }
 
@@ -202,36 +513,151 @@ func (c *Gcx) KillWait() error {
 
 // Group groups together several gcx'es. A group is used when one
 // wishes to wait on a number of contexts and be notified when one
-// (any) of them terminates.
+// (any) of them terminates. A group can also be used to kill all its
+// members at once (see Group.KillAll).
 //
 // You can set the group of a gcx by calling Gcx.SetGroup. A context
 // is considered member of the group from the time it is started (it
 // becomes running) until it terminates *and* a call to Group.Wait,
-// Group.Poll or Group.Notify returns its exit-status. Getting the
-// gcx's exit status via Gcx.Wait does *not* remove it from the
-// group.
+// Group.Poll or Group.Drain (or, Group.Notify) returns its
+// exit-status. Getting the gcx's exit status via Gcx.Wait does *not*
+// remove it from the group.
 //
-// ATTENTION: If a group is garbage-collected (that is, all references
-// to it are dropped) while it still has gcx's for which their exit
-// status has not been retrieved (by calling Group.Wait, Group.Poll or
-// Group.Notify) then you program will leak goroutines. Therefore,
-// before dropping a group, make sure you have retrieved the exit
-// status of all its member gcx's using Group.Wait, Group.Poll and/or
-// Group.Notify.
+// Terminated contexts are queued in the group until their exit status
+// is retrieved; a terminating context never waits for this to
+// happen. Method Group.ChReady returns a channel that can be used to
+// multiplex waiting for context termination with other channel
+// operations in a select statement.
 type Group struct {
-	mu     sync.Mutex
-	n      int
-	notify chan *Gcx
+	mu      sync.Mutex
+	members map[*Gcx]struct{}
+	done    []*Gcx        // terminated members, in order
+	head    int           // index of first entry in done
+	ready   chan struct{} // signaled when done is not empty
+	notify  chan *Gcx     // see ChNotify
+	fwd     bool          // notify forwarder running
+	fwdc    *Gcx          // context held by the forwarder
+	reclaim chan struct{} // asks the forwarder to give back fwdc
+	obs     Observer
+}
+
+// join adds c to the members of g.
+func (g *Group) join(c *Gcx) {
+	g.mu.Lock()
+	if g.members == nil {
+		g.members = make(map[*Gcx]struct{})
+	}
+	g.members[c] = struct{}{}
+	g.mu.Unlock()
+}
+
+// leave removes c from the members of g.
+func (g *Group) leave(c *Gcx) {
+	g.mu.Lock()
+	delete(g.members, c)
+	g.mu.Unlock()
 }
 
 func (g *Group) init() {
 	g.mu.Lock()
-	if g.notify == nil {
-		g.notify = make(chan *Gcx)
+	if g.ready == nil {
+		g.ready = make(chan struct{}, 1)
+		g.reclaim = make(chan struct{}, 1)
 	}
 	g.mu.Unlock()
 }
 
+// ring signals the ready channel of g, if not already signaled.
+func (g *Group) ring() {
+	select {
+	case g.ready <- struct{}{}:
+	default:
+	}
+}
+
+// finish queues the terminated context c. It never blocks.
+func (g *Group) finish(c *Gcx) {
+	g.mu.Lock()
+	g.push(c)
+	fwd := g.notify != nil && !g.fwd
+	if fwd {
+		g.fwd = true
+	}
+	g.mu.Unlock()
+	if fwd {
+		go g.forward()
+	}
+	g.ring()
+}
+
+// push appends the terminated context c to the queue of g. The
+// queue's storage is reused: entries already removed from its head
+// are reclaimed before it grows. Must be called with g.mu held.
+func (g *Group) push(c *Gcx) {
+	if g.head > 0 && len(g.done) == cap(g.done) {
+		n := copy(g.done, g.done[g.head:])
+		for i := n; i < len(g.done); i++ {
+			g.done[i] = nil
+		}
+		g.done, g.head = g.done[:n], 0
+	}
+	g.done = append(g.done, c)
+}
+
+// unshift puts the terminated context c back at the head of the
+// queue of g. Must be called with g.mu held.
+func (g *Group) unshift(c *Gcx) {
+	if g.head > 0 {
+		g.head--
+		g.done[g.head] = c
+		return
+	}
+	g.done = append(g.done, nil)
+	copy(g.done[1:], g.done)
+	g.done[0] = c
+}
+
+// shift removes and returns the first terminated context from the
+// queue of g, or nil if the queue is empty. If the queue is empty,
+// and the notify forwarder holds a context, shift asks the forwarder
+// to give it back (see Group.ChNotify). Must be called with g.mu
+// held.
+func (g *Group) shift() *Gcx {
+	if g.head == len(g.done) {
+		if g.fwdc != nil {
+			select {
+			case g.reclaim <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	}
+	c := g.done[g.head]
+	g.done[g.head] = nil
+	g.head++
+	if g.head == len(g.done) {
+		g.done, g.head = g.done[:0], 0
+	}
+	return c
+}
+
+// pop removes the first terminated context from the queue, and from
+// the members, of g. It returns nil if there is no terminated
+// context.
+func (g *Group) pop() *Gcx {
+	g.mu.Lock()
+	c := g.shift()
+	if c != nil {
+		delete(g.members, c)
+	}
+	more := g.head != len(g.done)
+	g.mu.Unlock()
+	if more {
+		g.ring()
+	}
+	return c
+}
+
 // SetGroup sets the group of gcx c to g. A gcx can belong to only one
 // group (or no group at all). The group of a context must be set
 // before it is started (befroe the first Gcx.Go call). If SetGroup is
@@ -239,8 +665,8 @@ func (g *Group) init() {
 //
 // Once added and started, the gcx is considered member of the
 // group. It remains so until it terminates *and* Group.Wait,
-// Group.Poll, or Group.Notify return its exit status. See doc of
-// Group.Wait for more.
+// Group.Poll, Group.Drain, or Group.Notify return its exit status.
+// See doc of Group.Wait for more.
 func (c *Gcx) SetGroup(g *Group) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -254,22 +680,21 @@ func (c *Gcx) SetGroup(g *Group) {
 // Wait waits for one (any) of the contexts in group g to
 // terminate. It returns a pointer to the Gcx structure of the context
 // that terminated, and its exit status. If upon entry to Group.Wait,
-// the group has no more gcx's, it returns nil, nil.
+// the group has no more gcx's, it returns nil, nil. Contexts are
+// returned in order of termination.
 //
 // Once Group.Wait returns a context and exit status, then the context
 // is no longer considered a member of the group.
 func (g *Group) Wait() (c *Gcx, xs error) {
-	g.mu.Lock()
-	n := g.n
-	g.mu.Unlock()
-	if n == 0 {
-		return nil, nil
+	for {
+		if g.Count() == 0 {
+			return nil, nil
+		}
+		if c = g.pop(); c != nil {
+			return c, c.Wait()
+		}
+		<-g.ready
 	}
-	c = <-g.notify
-	g.mu.Lock()
-	g.n--
-	g.mu.Unlock()
-	return c, c.Wait()
 }
 
 // Poll checks if a (any) gcx in g has already terminated, and if so
@@ -280,39 +705,111 @@ func (g *Group) Wait() (c *Gcx, xs error) {
 // Once Group.Poll returns a goroutine's context and exit status, then
 // the goroutine is no longer considered a member of the group.
 func (g *Group) Poll() (c *Gcx, xs error) {
-	g.mu.Lock()
-	n := g.n
-	g.mu.Unlock()
-	if n == 0 {
-		return nil, nil
-	}
-	select {
-	case c = <-g.notify:
-	default:
+	if c = g.pop(); c == nil {
 		return nil, nil
 	}
+	return c, c.Wait()
+}
+
+// Drain appends to ss all the contexts of group g that have
+// terminated, and their exit statuses, in order of termination,
+// without waiting, and returns the extended slice. If there is no
+// terminated context, ss is returned unchanged. The contexts returned
+// are no longer considered members of the group. In order to avoid
+// allocations, callers can pass the slice returned by a previous
+// call, truncated to zero length.
+func (g *Group) Drain(ss []GcxStatus) []GcxStatus {
 	g.mu.Lock()
-	g.n--
+	n := len(ss)
+	for c := g.shift(); c != nil; c = g.shift() {
+		delete(g.members, c)
+		ss = append(ss, GcxStatus{Gcx: c})
+	}
 	g.mu.Unlock()
-	return c, c.Wait()
+	for i := n; i < len(ss); i++ {
+		ss[i].Status = ss[i].Gcx.Wait()
+	}
+	return ss
+}
+
+// ChReady returns a channel that becomes ready for receiving when
+// contexts of group g have terminated. Once a value is received, the
+// terminated contexts can be retrieved with Group.Poll or
+// Group.Drain, without blocking. If, after receiving, not all of the
+// terminated contexts are retrieved, the channel becomes ready
+// again. A receive may, occasionally, be spurious (find no context
+// terminated), so the caller must be prepared for Poll to return nil.
+//
+// ChReady is useful when one wishes to multiplex the wait for context
+// termination with other channel operations in a select statement:
+//
+//	for {
+//		select {
+//		case <-g.ChReady():
+//			ss = g.Drain(ss[:0])
+//			for _, s := range ss {
+//				...
+//			}
+//		case <-other:
+//			...
+//		}
+//	}
+func (g *Group) ChReady() <-chan struct{} {
+	g.init()
+	return g.ready
 }
 
 // Count returns the number of gcx's in the group.
 func (g *Group) Count() int {
 	g.mu.Lock()
-	n := g.n
+	n := len(g.members)
 	g.mu.Unlock()
 	return n
 }
 
+// GcxStatus pairs a terminated context with its exit status.
+type GcxStatus struct {
+	Gcx    *Gcx
+	Status error
+}
+
 // WaitAll calls Group.Wait repeatedly until all the gcx's in group g
-// terminate. The exit status'es returned by the repeated calls to
-// Group.Wait are discarded.
-func (g *Group) WaitAll() {
-	c, _ := g.Wait()
-	for c != nil {
-		c, _ = g.Wait()
+// terminate. It returns the contexts and exit statuses returned by
+// the repeated calls to Group.Wait, in order of termination.
+func (g *Group) WaitAll() []GcxStatus {
+	var ss []GcxStatus
+	for c, xs := g.Wait(); c != nil; c, xs = g.Wait() {
+		ss = append(ss, GcxStatus{c, xs})
 	}
+	return ss
+}
+
+// Members returns the contexts that are currently members of group
+// g, in no particular order.
+func (g *Group) Members() []*Gcx {
+	g.mu.Lock()
+	cs := make([]*Gcx, 0, len(g.members))
+	for c := range g.members {
+		cs = append(cs, c)
+	}
+	g.mu.Unlock()
+	return cs
+}
+
+// KillAll kills (see Gcx.Kill) all contexts that are currently
+// members of group g. Contexts that are already dead are not
+// affected.
+func (g *Group) KillAll() {
+	for _, c := range g.Members() {
+		c.Kill()
+	}
+}
+
+// KillWaitAll is the same as calling Group.KillAll, followed by
+// Group.WaitAll.
+func (g *Group) KillWaitAll() []GcxStatus {
+	g.KillAll()
+	return g.WaitAll()
 }
 
 // ChNotify returns a channel upon which the caller can receive gcx
@@ -323,22 +820,85 @@ func (g *Group) WaitAll() {
 // pointer. Group.Notify will return the gcx's exit status and the
 // context will no longer be considered a member of the group.
 //
-// ChNotify / Notify are useful when one wishes to multiplex the wait
-// for context termination with other channel operations in a select
-// statement.
+// Once ChNotify is called, terminated contexts are delivered to the
+// channel by a helper goroutine, which runs while there are
+// undelivered notifications. If Group.Wait (or Group.WaitUntil,
+// Group.Poll, Group.Drain) is called while the helper goroutine waits
+// to deliver a context, the helper gives the context back, and exits,
+// so that the caller can retrieve it instead. Each terminated context
+// is returned either through the channel, or by one of these methods,
+// never both.
+//
+// Deprecated: Use Group.ChReady, which needs no call to
+// Group.Notify, and does not require a helper goroutine.
 func (g *Group) ChNotify() <-chan *Gcx {
 	g.init()
+	g.mu.Lock()
+	if g.notify == nil {
+		g.notify = make(chan *Gcx)
+	}
+	fwd := g.head != len(g.done) && !g.fwd
+	if fwd {
+		g.fwd = true
+	}
+	g.mu.Unlock()
+	if fwd {
+		go g.forward()
+	}
 	return g.notify
 }
 
+// forward delivers the terminated contexts of g to the notify
+// channel, until there are no more, or until asked to give back the
+// context it holds (see Group.shift).
+func (g *Group) forward() {
+	for {
+		g.mu.Lock()
+		c := g.shift()
+		if c == nil {
+			g.fwd = false
+			g.mu.Unlock()
+			return
+		}
+		g.fwdc = c
+		g.mu.Unlock()
+		select {
+		case g.notify <- c:
+			g.mu.Lock()
+			g.fwdc = nil
+			// Discard a request that arrived too late.
+			select {
+			case <-g.reclaim:
+			default:
+			}
+			g.mu.Unlock()
+		case <-g.reclaim:
+			g.mu.Lock()
+			g.fwdc = nil
+			// Discard a repeated request.
+			select {
+			case <-g.reclaim:
+			default:
+			}
+			g.unshift(c)
+			g.fwd = false
+			g.mu.Unlock()
+			g.ring()
+			return
+		}
+	}
+}
+
 // Notify *MUST ALWAYS* and *ONLY* be called with the context pointers
 // received from the channel returned by Group.ChNotify. It returns
 // the respective context's exit status. *ANY* other use of Notify is
 // an error and will leave the group in an invalid internal state. See
 // also Group.ChNotify.
+//
+// Deprecated: Use Group.ChReady.
 func (g *Group) Notify(c *Gcx) error {
-	g.mu.Lock()
-	g.n--
-	g.mu.Unlock()
+	g.leave(c)
+	// Wake up waiters, in case the group is now empty.
+	g.ring()
 	return c.Wait()
 }
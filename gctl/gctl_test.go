@@ -10,6 +10,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/npat-efault/gohacks/errors"
 )
 
 func TestRaceGcxWait(t *testing.T) {
@@ -36,16 +38,6 @@ func TestRaceGcxKill(t *testing.T) {
 	gcx.Kill()
 }
 
-func TestRaceGcxKillSignaled(t *testing.T) {
-	// data race on Gcx.signaled
-	var gcx Gcx
-	go gcx.Go(func() error { return nil })
-	time.Sleep(10 * time.Millisecond)
-	go gcx.Kill()
-	time.Sleep(10 * time.Millisecond)
-	gcx.Kill()
-}
-
 func TestGcxChKillPanic(t *testing.T) {
 	defer func() {
 		x := recover()
@@ -160,6 +152,76 @@ func TestKillWait(t *testing.T) {
 	}
 }
 
+func TestKillReason(t *testing.T) {
+	defer Track()()
+	errOp := errors.New("operator")
+	var c Gcx
+	c.Go(func() error {
+		<-c.ChKill()
+		if r := c.Reason(); r != errOp {
+			t.Errorf("Reason in goroutine: %v", r)
+		}
+		return ErrKilled
+	})
+	if r := c.Reason(); r != nil {
+		t.Fatalf("Reason before kill: %v", r)
+	}
+	c.KillWithReason(errOp)
+	c.KillWithReason(errors.New("second"))
+	if e := c.Wait(); e != ErrKilled {
+		t.Fatalf("Wait: %v", e)
+	}
+	if r := c.Reason(); r != errOp {
+		t.Fatalf("Reason: %v", r)
+	}
+
+	// Goroutine failure
+	errFail := errors.New("fail")
+	var c1, k Gcx
+	c1.Go(waitKill(&c1))
+	k.SetParent(&c1, ChildIgnore)
+	k.Go(waitKill(&k))
+	c1.GoNamed("failer", func() error { return errFail })
+	if e := c1.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+	fe, ok := c1.Reason().(*FailError)
+	if !ok || fe.Err != errFail || fe.Name != "failer" || fe.Child ||
+		!strings.Contains(fe.Loc.String(), "gctl_test.go:") {
+		t.Fatalf("Reason: %#v", c1.Reason())
+	}
+	if r := k.Reason(); r != ErrParentKilled {
+		t.Fatalf("Child reason: %v", r)
+	}
+
+	// Child failure
+	var c2, k2 Gcx
+	c2.Go(waitKill(&c2))
+	k2.SetParent(&c2, ChildKill)
+	k2.Go(func() error { return errFail })
+	c2.Wait()
+	if fe, ok := c2.Reason().(*FailError); !ok || fe.Err != errFail || !fe.Child {
+		t.Fatalf("Reason: %#v", c2.Reason())
+	}
+
+	// Deadline
+	var c3 Gcx
+	c3.Go(waitKill(&c3))
+	c3.KillAfter(5 * time.Millisecond)
+	if e := c3.Wait(); e != ErrDeadline || c3.Reason() != ErrDeadline {
+		t.Fatalf("Wait: %v, Reason: %v", e, c3.Reason())
+	}
+
+	// Not killed
+	var c4 Gcx
+	c4.Go(func() error { return nil })
+	c4.Wait()
+	c4.Kill()
+	if r := c4.Reason(); r != nil {
+		t.Fatalf("Reason, not killed: %v", r)
+	}
+}
+
 func TestGroupUninit(t *testing.T) {
 	var c *Gcx
 	var xs error
@@ -286,3 +348,179 @@ func TestGroupMany(t *testing.T) {
 	}
 	//t.Logf("n1 := %d, n2 = %d, total = %d", n1, n-n1, N+N*N)
 }
+
+func TestGroupKillAll(t *testing.T) {
+	var g Group
+	cs := make([]*Gcx, 4)
+	for i := range cs {
+		c := &Gcx{}
+		c.SetGroup(&g)
+		c.Go(waitKill(c))
+		cs[i] = c
+	}
+	ms := g.Members()
+	if len(ms) != len(cs) {
+		t.Fatalf("Group.Members: %d members", len(ms))
+	}
+	ss := g.KillWaitAll()
+	if len(ss) != len(cs) {
+		t.Fatalf("Group.KillWaitAll: %d statuses", len(ss))
+	}
+	for _, s := range ss {
+		if s.Status != ErrKilled {
+			t.Fatalf("Group.KillWaitAll: status %v", s.Status)
+		}
+	}
+	if n := g.Count(); n != 0 {
+		t.Fatalf("Group.Count: %d", n)
+	}
+	if ms := g.Members(); len(ms) != 0 {
+		t.Fatalf("Group.Members: %d members", len(ms))
+	}
+}
+
+func TestGroupDrain(t *testing.T) {
+	var g Group
+	if ss := g.Drain(nil); ss != nil {
+		t.Fatalf("Group.Drain: %v", ss)
+	}
+	errFail := errors.New("fail")
+	cs := make([]*Gcx, 4)
+	for i := range cs {
+		c := &Gcx{}
+		c.SetGroup(&g)
+		if i == 2 {
+			c.Go(func() error { return errFail })
+		} else {
+			c.Go(func() error { return nil })
+		}
+		// No-one is reading notifications; termination must
+		// not block.
+		c.Wait()
+		cs[i] = c
+	}
+	var last Gcx
+	last.SetGroup(&g)
+	last.Go(waitKill(&last))
+
+	// Gcx.Wait may return before the context is queued in the
+	// group, so collect the statuses until all have arrived.
+	var ss []GcxStatus
+	tmo := time.After(time.Second)
+	for len(ss) < len(cs) {
+		select {
+		case <-g.ChReady():
+		case <-tmo:
+			t.Fatalf("Group.Drain: %d statuses", len(ss))
+		}
+		ss = g.Drain(ss)
+	}
+	if len(ss) != len(cs) {
+		t.Fatalf("Group.Drain: %d statuses", len(ss))
+	}
+	for i, s := range ss {
+		if s.Gcx != cs[i] {
+			t.Fatalf("Group.Drain: bad order at %d", i)
+		}
+		if i == 2 && s.Status != errFail || i != 2 && s.Status != nil {
+			t.Fatalf("Group.Drain: status %d: %v", i, s.Status)
+		}
+	}
+	if n := g.Count(); n != 1 {
+		t.Fatalf("Group.Count: %d", n)
+	}
+	if c, _ := g.Poll(); c != nil {
+		t.Fatalf("Group.Poll: %p", c)
+	}
+
+	last.Kill()
+	for c := (*Gcx)(nil); c == nil; {
+		select {
+		case <-g.ChReady():
+			c, _ = g.Poll()
+		case <-time.After(time.Second):
+			t.Fatal("Group.ChReady: not ready")
+		}
+		if c != nil && c != &last {
+			t.Fatalf("Group.Poll: %p", c)
+		}
+	}
+	if n := g.Count(); n != 0 {
+		t.Fatalf("Group.Count: %d", n)
+	}
+}
+
+func TestGroupChNotify(t *testing.T) {
+	var g Group
+	for i := 0; i < 3; i++ {
+		c := &Gcx{}
+		c.SetGroup(&g)
+		c.Go(func() error { return nil })
+		c.Wait()
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case c := <-g.ChNotify():
+			if e := g.Notify(c); e != nil {
+				t.Fatalf("Group.Notify: %v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Group.ChNotify: no notification")
+		}
+	}
+	if n := g.Count(); n != 0 {
+		t.Fatalf("Group.Count: %d", n)
+	}
+}
+
+func TestGroupChNotifyWait(t *testing.T) {
+	var g Group
+	start := func() *Gcx {
+		c := &Gcx{}
+		c.SetGroup(&g)
+		c.Go(func() error { return nil })
+		c.Wait()
+		return c
+	}
+	waitAll := func() []GcxStatus {
+		done := make(chan []GcxStatus)
+		go func() { done <- g.WaitAll() }()
+		select {
+		case ss := <-done:
+			return ss
+		case <-time.After(time.Second):
+			t.Fatal("Group.WaitAll: blocked")
+			return nil
+		}
+	}
+	for i := 0; i < 3; i++ {
+		start()
+	}
+	// Take one context through ChNotify; the helper goroutine
+	// then blocks delivering the next one. WaitAll must get it
+	// back, and return the remaining contexts.
+	select {
+	case c := <-g.ChNotify():
+		g.Notify(c)
+	case <-time.After(time.Second):
+		t.Fatal("Group.ChNotify: no notification")
+	}
+	if ss := waitAll(); len(ss) != 2 {
+		t.Fatalf("Group.WaitAll: %d statuses", len(ss))
+	}
+
+	// Notifications continue to work afterwards.
+	c := start()
+	select {
+	case cn := <-g.ChNotify():
+		if cn != c {
+			t.Fatalf("Group.ChNotify: %p != %p", cn, c)
+		}
+		g.Notify(cn)
+	case <-time.After(time.Second):
+		t.Fatal("Group.ChNotify: no notification")
+	}
+	if ss := waitAll(); len(ss) != 0 {
+		t.Fatalf("Group.WaitAll: %d statuses", len(ss))
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+// Package gcxhttp provides an HTTP handler that serves the
+// descriptions of all live goroutine contexts (see gctl.Snapshot). It
+// is a separate package so that importing gctl does not import
+// net/http.
+package gcxhttp
+
+import (
+	"net/http"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+// Handler returns an http.Handler that serves the descriptions of all
+// live contexts. It enables tracking (see gctl.Track), so only the
+// contexts started after the first call to Handler are described.
+// By default the output is the same as that of
+// gctl.Dump; if the request has the query parameter "json" set (to
+// any value) the output is the same as that of gctl.DumpJSON. The
+// handler is not registered anywhere, it is up to the caller to do
+// so, e.g.:
+//
+//	http.Handle("/debug/gctl", gcxhttp.Handler())
+func Handler() http.Handler {
+	gctl.Track()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["json"]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			gctl.DumpJSON(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		gctl.Dump(w)
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gcxhttp
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	var c gctl.Gcx
+	c.GoNamed("waiter", func() error {
+		<-c.ChKill()
+		return nil
+	})
+	ci, _ := c.Info()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/gctl?json", nil))
+	var js []struct{ ID uint64 }
+	if err := json.Unmarshal(rec.Body.Bytes(), &js); err != nil {
+		t.Fatalf("Bad JSON: %v", err)
+	}
+	found := false
+	for _, j := range js {
+		found = found || j.ID == ci.ID
+	}
+	if !found {
+		t.Fatalf("Context %d not in JSON: %s", ci.ID, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/gctl", nil))
+	if !strings.Contains(rec.Body.String(), "waiter [gcxhttp/gcxhttp_test.go:") {
+		t.Fatalf("Bad dump: %s", rec.Body.String())
+	}
+	c.KillWait()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+// Package gcxtest provides helpers for testing code that uses gctl
+// goroutine contexts.
+package gcxtest
+
+import (
+	"time"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+// TB is the subset of testing.TB used by the package.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(f func())
+}
+
+// Checker detects goroutine contexts leaked by a test. See
+// Checker.Check.
+type Checker struct {
+	// Grace is the time allowed for the contexts started during
+	// the test to terminate, once the test is done, before they
+	// are considered leaked.
+	Grace time.Duration
+	// If Kill is true, leaked contexts are killed, and waited for
+	// (for no longer than KillTimeout, or one second if zero)
+	// before they are reported.
+	Kill        bool
+	KillTimeout time.Duration
+}
+
+// Check is the same as calling Checker.Check with a zero Checker.
+func Check(t TB) {
+	t.Helper()
+	Checker{}.Check(t)
+}
+
+// Check arranges for test t to fail if any goroutine context started
+// after the call to Check is still live when the test (and its
+// subtests) complete. For each leaked context, the test's error
+// messages list the goroutines still running in it, with their start
+// locations and names. Check enables tracking (see gctl.Track) until
+// the test completes, and must be called at the beginning of the
+// test. Contexts started by other tests running in parallel are also
+// tracked, so Check is not useful with parallel tests.
+func (ck Checker) Check(t TB) {
+	t.Helper()
+	untrack := gctl.Track()
+	since := gctl.LastID()
+	t.Cleanup(func() {
+		t.Helper()
+		defer untrack()
+		leaked := ck.leaked(since)
+		if len(leaked) == 0 {
+			return
+		}
+		if ck.Kill {
+			tmo := ck.KillTimeout
+			if tmo == 0 {
+				tmo = time.Second
+			}
+			for _, ci := range leaked {
+				if c := gctl.Lookup(ci.ID); c != nil {
+					c.Kill()
+					c.WaitTimeout(tmo)
+				}
+			}
+		}
+		for _, ci := range leaked {
+			n := 0
+			for _, g := range ci.Goroutines {
+				if g.State != gctl.GoRunning {
+					continue
+				}
+				name := ""
+				if g.Name != "" {
+					name = " " + g.Name
+				}
+				t.Errorf("Leaked gcx %d: goroutine%s started at %s",
+					ci.ID, name, g.Loc)
+				n++
+			}
+			if n == 0 {
+				t.Errorf("Leaked gcx %d: %d child contexts",
+					ci.ID, ci.Children)
+			}
+		}
+	})
+}
+
+// leaked returns the live contexts with ids greater than since,
+// after waiting up to ck.Grace for them to terminate.
+func (ck Checker) leaked(since uint64) []gctl.GcxInfo {
+	deadline := time.Now().Add(ck.Grace)
+	for {
+		var ls []gctl.GcxInfo
+		for _, ci := range gctl.Snapshot() {
+			if ci.ID > since {
+				ls = append(ls, ci)
+			}
+		}
+		if len(ls) == 0 || !time.Now().Before(deadline) {
+			return ls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
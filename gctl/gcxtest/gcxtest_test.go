@@ -0,0 +1,63 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gcxtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+type fakeT struct {
+	errs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) done() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestCheckClean(t *testing.T) {
+	Checker{Grace: time.Second}.Check(t)
+	var c gctl.Gcx
+	c.Go(func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+}
+
+func TestCheckLeak(t *testing.T) {
+	ft := &fakeT{}
+	Checker{Grace: 20 * time.Millisecond, Kill: true}.Check(ft)
+	var c gctl.Gcx
+	c.GoNamed("sleeper", func() error {
+		<-c.ChKill()
+		return gctl.ErrKilled
+	})
+	ft.done()
+	if len(ft.errs) != 1 ||
+		!strings.Contains(ft.errs[0], "goroutine sleeper started at gcxtest/gcxtest_test.go:") {
+		t.Fatalf("Bad errors: %q", ft.errs)
+	}
+	if e := c.WaitTimeout(0); e != gctl.ErrKilled {
+		t.Fatalf("Leaked context not killed: %v", e)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gcxtest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sched is a deterministic goroutine scheduler, for testing code that
+// uses goroutine contexts (see gctl.Gcx.SetScheduler). Goroutines of
+// contexts using a Sched run one at a time; the running goroutine
+// keeps running until it returns, or until it calls Sched.Yield or
+// Sched.Block, at which point the scheduler picks the next goroutine
+// to run, pseudo-randomly, based on its seed. Provided that the
+// goroutines' code is itself deterministic, the same seed results in
+// the same interleaving. Running the same test with different seeds
+// (see Explore) exercises different interleavings; a failing seed can
+// be replayed by running the test with a Sched created with that
+// seed.
+//
+// Goroutines under a Sched must not block, other than by calling
+// Sched.Block, since no other goroutine can run while they do. They
+// must, for example, use Sched.Block(c.ChKill()) instead of receiving
+// from the kill channel directly. Goroutines started by other means
+// (timers, deadlines, plain go statements) are not controlled by the
+// scheduler.
+type Sched struct {
+	mu      sync.Mutex
+	seed    int64
+	rnd     *rand.Rand
+	tasks   []*task // live tasks, in order of creation
+	running *task
+	n       int           // number of tasks created
+	idle    chan struct{} // signaled when there is nothing to run
+	trace   []string
+}
+
+type task struct {
+	name  string
+	wake  chan struct{}
+	block <-chan struct{} // waiting for this to close
+}
+
+// NewSched returns a new scheduler that picks goroutines based on
+// the given seed.
+func NewSched(seed int64) *Sched {
+	return &Sched{
+		seed: seed,
+		rnd:  rand.New(rand.NewSource(seed)),
+		idle: make(chan struct{}, 1),
+	}
+}
+
+// Seed returns the seed of the scheduler.
+func (s *Sched) Seed() int64 { return s.seed }
+
+// Spawn implements gctl.Scheduler.
+func (s *Sched) Spawn(name string) (begin, end func()) {
+	s.mu.Lock()
+	s.n++
+	if name == "" {
+		name = "goroutine"
+	}
+	t := &task{
+		name: fmt.Sprintf("%s#%d", name, s.n),
+		wake: make(chan struct{}, 1),
+	}
+	s.tasks = append(s.tasks, t)
+	s.mu.Unlock()
+	begin = func() { <-t.wake }
+	end = func() {
+		s.mu.Lock()
+		for i, tt := range s.tasks {
+			if tt == t {
+				s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+				break
+			}
+		}
+		s.running = nil
+		s.next()
+		s.mu.Unlock()
+	}
+	return begin, end
+}
+
+// ready returns true if task t can run. Must be called with s.mu
+// held.
+func (t *task) ready() bool {
+	if t.block == nil {
+		return true
+	}
+	select {
+	case <-t.block:
+		return true
+	default:
+		return false
+	}
+}
+
+// next picks the next task to run and wakes it up. If no task can
+// run, it signals the goroutine blocked in Run. Must be called with
+// s.mu held.
+func (s *Sched) next() {
+	var rs []*task
+	for _, t := range s.tasks {
+		if t.ready() {
+			rs = append(rs, t)
+		}
+	}
+	if len(rs) == 0 {
+		select {
+		case s.idle <- struct{}{}:
+		default:
+		}
+		return
+	}
+	t := rs[s.rnd.Intn(len(rs))]
+	t.block = nil
+	s.running = t
+	s.trace = append(s.trace, t.name)
+	t.wake <- struct{}{}
+}
+
+// switchOut makes the running task wait until scheduled again (or
+// until ch is closed and it is scheduled, if ch is not nil).
+func (s *Sched) switchOut(ch <-chan struct{}) {
+	s.mu.Lock()
+	t := s.running
+	if t == nil {
+		s.mu.Unlock()
+		panic("gcxtest.Sched: Yield or Block called outside a scheduled goroutine")
+	}
+	t.block = ch
+	s.running = nil
+	s.next()
+	s.mu.Unlock()
+	<-t.wake
+}
+
+// Yield lets the scheduler run another goroutine (or the calling one
+// again). It must only be called from goroutines run by the
+// scheduler.
+func (s *Sched) Yield() {
+	s.switchOut(nil)
+}
+
+// Block waits until channel ch is closed (e.g. the channel returned by
+// gctl.Gcx.ChKill), letting the scheduler run other goroutines in the
+// meantime. It must only be called from goroutines run by the
+// scheduler, and only with channels that are closed (and never sent
+// to) to signal readiness.
+func (s *Sched) Block(ch <-chan struct{}) {
+	s.switchOut(ch)
+}
+
+// Run runs the goroutines started (under the scheduler) so far, and
+// those they start, until none of them can run. It returns an error
+// if goroutines remain blocked in Sched.Block (a deadlock), or if the
+// running goroutine does not yield, block, or return within timeout
+// (for example, because it blocks outside the scheduler's control).
+// Goroutines must not be started under the scheduler, from outside
+// the scheduled goroutines, while Run is running.
+func (s *Sched) Run(timeout time.Duration) error {
+	s.mu.Lock()
+	select {
+	case <-s.idle:
+	default:
+	}
+	if s.running == nil {
+		s.next()
+	}
+	s.mu.Unlock()
+	tm := time.NewTimer(timeout)
+	defer tm.Stop()
+	select {
+	case <-s.idle:
+	case <-tm.C:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		name := "none"
+		if s.running != nil {
+			name = s.running.name
+		}
+		return fmt.Errorf("gcxtest.Sched: seed %d: stalled, running: %s",
+			s.seed, name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tasks) != 0 {
+		ns := make([]string, len(s.tasks))
+		for i, t := range s.tasks {
+			ns[i] = t.name
+		}
+		return fmt.Errorf("gcxtest.Sched: seed %d: deadlock, blocked: %s",
+			s.seed, strings.Join(ns, ", "))
+	}
+	return nil
+}
+
+// Trace returns the names of the goroutines, in the order they were
+// scheduled to run. Each goroutine is named after the name given to
+// gctl.Gcx.GoNamed (or "goroutine"), followed by "#" and a sequence
+// number.
+func (s *Sched) Trace() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.trace...)
+}
+
+// Explore runs test function f n times, each time with a new Sched,
+// seeded with first, first+1, and so on. It stops at the first run
+// for which f returns an error, and returns the respective seed and
+// error. The failing run can be replayed by calling f with
+// NewSched(seed). If all runs succeed, Explore returns 0, nil.
+func Explore(first int64, n int, f func(s *Sched) error) (int64, error) {
+	for seed := first; seed < first+int64(n); seed++ {
+		if err := f(NewSched(seed)); err != nil {
+			return seed, err
+		}
+	}
+	return 0, nil
+}
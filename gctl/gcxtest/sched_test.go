@@ -0,0 +1,159 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gcxtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+// killRace kills a context from two goroutines, while a third waits
+// for the kill, and a child context runs. It returns the scheduling
+// trace.
+func killRace(s *Sched) ([]string, error) {
+	var c, k gctl.Gcx
+	c.SetScheduler(s)
+	c.GoNamed("waiter", func() error {
+		s.Block(c.ChKill())
+		return gctl.ErrKilled
+	})
+	k.SetParent(&c, gctl.ChildKill)
+	k.GoNamed("child", func() error {
+		s.Block(k.ChKill())
+		return gctl.ErrKilled
+	})
+	for i := 0; i < 2; i++ {
+		c.GoNamed("killer", func() error {
+			s.Yield()
+			c.Kill()
+			return nil
+		})
+	}
+	if err := s.Run(time.Second); err != nil {
+		return nil, err
+	}
+	if e := c.Wait(); e != gctl.ErrKilled {
+		return nil, fmt.Errorf("Wait: %v", e)
+	}
+	if r := k.Reason(); r != gctl.ErrParentKilled {
+		return nil, fmt.Errorf("Child reason: %v", r)
+	}
+	return s.Trace(), nil
+}
+
+func TestRaceGcxKillSignaled(t *testing.T) {
+	// Concurrent kills, once raced on Gcx.signaled; explore the
+	// orders in which they can meet the context's goroutines.
+	seed, err := Explore(1, 20, func(s *Sched) error {
+		var c gctl.Gcx
+		c.SetScheduler(s)
+		c.GoNamed("waiter", func() error {
+			s.Block(c.ChKill())
+			return gctl.ErrKilled
+		})
+		for i := 0; i < 2; i++ {
+			c.GoNamed("killer", func() error {
+				s.Yield()
+				c.Kill()
+				return nil
+			})
+		}
+		if err := s.Run(time.Second); err != nil {
+			return err
+		}
+		if e := c.Wait(); e != gctl.ErrKilled {
+			return fmt.Errorf("Wait: %v", e)
+		}
+		if r := c.Reason(); r != gctl.ErrKilled {
+			return fmt.Errorf("Reason: %v", r)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, err)
+	}
+}
+
+func TestSchedDeterministic(t *testing.T) {
+	traces := make(map[string]bool)
+	for seed := int64(1); seed <= 20; seed++ {
+		tr1, err := killRace(NewSched(seed))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		tr2, err := killRace(NewSched(seed))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		s1, s2 := strings.Join(tr1, " "), strings.Join(tr2, " ")
+		if s1 != s2 {
+			t.Fatalf("seed %d: traces differ:\n%s\n%s", seed, s1, s2)
+		}
+		traces[s1] = true
+	}
+	if len(traces) < 2 {
+		t.Fatalf("No interleavings explored: %v", traces)
+	}
+}
+
+func TestSchedExplore(t *testing.T) {
+	// An order-dependent "bug": the check fails if the second
+	// goroutine runs before the first.
+	test := func(s *Sched) error {
+		var c gctl.Gcx
+		c.SetScheduler(s)
+		var order []string
+		c.GoNamed("a", func() error {
+			order = append(order, "a")
+			return nil
+		})
+		c.GoNamed("b", func() error {
+			order = append(order, "b")
+			return nil
+		})
+		if err := s.Run(time.Second); err != nil {
+			return err
+		}
+		c.Wait()
+		if order[0] != "a" {
+			return fmt.Errorf("bad order: %v", order)
+		}
+		return nil
+	}
+	seed, err := Explore(1, 50, test)
+	if err == nil {
+		t.Fatal("Explore: no failure found")
+	}
+	// Replay
+	for i := 0; i < 5; i++ {
+		if e := test(NewSched(seed)); e == nil || e.Error() != err.Error() {
+			t.Fatalf("Replay seed %d: %v", seed, e)
+		}
+	}
+}
+
+func TestSchedDeadlock(t *testing.T) {
+	s := NewSched(1)
+	var c gctl.Gcx
+	c.SetScheduler(s)
+	c.GoNamed("stuck", func() error {
+		s.Block(c.ChKill())
+		return gctl.ErrKilled
+	})
+	err := s.Run(time.Second)
+	if err == nil || !strings.Contains(err.Error(), "deadlock, blocked: stuck#1") {
+		t.Fatalf("Run: %v", err)
+	}
+	c.Kill()
+	if err := s.Run(time.Second); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	c.Wait()
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+// Package gcxvar provides a gctl.Observer that publishes goroutine
+// context metrics as expvar variables. It is a separate package so
+// that importing gctl does not register the expvar HTTP handler.
+package gcxvar
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+// Observer is a gctl.Observer that maintains its counters in an
+// expvar.Map. The map contains the following integer variables:
+//
+//	goroutines_started   goroutines started
+//	goroutines_running   goroutines currently running
+//	goroutines_failed    goroutines exited with non-nil status
+//	goroutines_time_ns   total goroutine run time, in nanoseconds
+//	contexts_live        contexts currently live
+//	contexts_killed      contexts killed
+//	contexts_dead        contexts terminated
+//	contexts_failed      contexts terminated with non-nil status
+type Observer struct {
+	m    *expvar.Map
+	mu   sync.Mutex
+	live map[uint64]struct{}
+}
+
+// New creates an Observer and publishes its counters as an expvar.Map
+// under the given name. Like expvar.Publish, it panics if the name is
+// already in use.
+func New(name string) *Observer {
+	return &Observer{
+		m:    expvar.NewMap(name),
+		live: make(map[uint64]struct{}),
+	}
+}
+
+// Map returns the expvar.Map where o keeps its counters.
+func (o *Observer) Map() *expvar.Map {
+	return o.m
+}
+
+// GoStart implements gctl.Observer. The first goroutine of a context
+// also counts the context as live.
+func (o *Observer) GoStart(id uint64, g gctl.GoInfo) {
+	o.mu.Lock()
+	if _, ok := o.live[id]; !ok {
+		// First goroutine of the context
+		o.live[id] = struct{}{}
+		o.m.Add("contexts_live", 1)
+	}
+	o.mu.Unlock()
+	o.m.Add("goroutines_started", 1)
+	o.m.Add("goroutines_running", 1)
+}
+
+// GoEnd implements gctl.Observer.
+func (o *Observer) GoEnd(id uint64, g gctl.GoInfo, d time.Duration) {
+	o.m.Add("goroutines_running", -1)
+	o.m.Add("goroutines_time_ns", int64(d))
+	if g.Err != nil {
+		o.m.Add("goroutines_failed", 1)
+	}
+}
+
+// Kill implements gctl.Observer.
+func (o *Observer) Kill(id uint64, xs error) {
+	o.m.Add("contexts_killed", 1)
+}
+
+// Dead implements gctl.Observer.
+func (o *Observer) Dead(id uint64, xs error, d time.Duration) {
+	o.mu.Lock()
+	delete(o.live, id)
+	o.mu.Unlock()
+	o.m.Add("contexts_live", -1)
+	o.m.Add("contexts_dead", 1)
+	if xs != nil {
+		o.m.Add("contexts_failed", 1)
+	}
+}
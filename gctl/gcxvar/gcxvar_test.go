@@ -0,0 +1,43 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gcxvar
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/npat-efault/gohacks/errors"
+	"github.com/npat-efault/gohacks/gctl"
+)
+
+// The expvar map can only be published once per process, so it is
+// shared by all runs of the test (e.g. with -count).
+var testObs = New("gctl_test")
+
+func TestObserver(t *testing.T) {
+	o := testObs
+	o.Map().Init()
+	var c gctl.Gcx
+	c.SetObserver(o)
+	c.Go(func() error { return nil })
+	c.Go(func() error { return errors.New("failed") })
+	c.Wait()
+
+	for name, want := range map[string]int64{
+		"goroutines_started": 2,
+		"goroutines_running": 0,
+		"goroutines_failed":  1,
+		"contexts_live":      0,
+		"contexts_killed":    1,
+		"contexts_dead":      1,
+		"contexts_failed":    1,
+	} {
+		v, ok := o.Map().Get(name).(*expvar.Int)
+		if !ok || v.Value() != want {
+			t.Fatalf("%s: %v != %d", name, o.Map().Get(name), want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// StallError is the exit status of a context killed by a heartbeat
+// watchdog (see Gcx.GoHeartbeat). It tests true with
+// errors.IsTimeout().
+type StallError struct {
+	Name   string          // Name of the stalled goroutine
+	Loc    errors.Location // Where the goroutine was started from, if tracked
+	Silent time.Duration   // Time since the last heartbeat
+}
+
+func (e *StallError) Error() string {
+	name := e.Name
+	if name == "" {
+		name = "goroutine"
+	}
+	if e.Loc.IsSet() {
+		name += " started at " + e.Loc.String()
+	}
+	return fmt.Sprintf("Gcx goroutine stalled: %s, no heartbeat for %v",
+		name, e.Silent)
+}
+
+// Timeout returns true.
+func (e *StallError) Timeout() bool { return true }
+
+// Heartbeat is the handle a goroutine started with Gcx.GoHeartbeat
+// uses to signal that it is making progress.
+type Heartbeat struct {
+	last int64 // UnixNano of last beat, atomic
+}
+
+// Beat records a heartbeat. It is cheap and can be called as often as
+// convenient.
+func (hb *Heartbeat) Beat() {
+	atomic.StoreInt64(&hb.last, time.Now().UnixNano())
+}
+
+// Last returns the time of the last heartbeat (or of the start of the
+// goroutine, if it has not called Beat yet).
+func (hb *Heartbeat) Last() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&hb.last))
+}
+
+// HeartbeatOpts are options for goroutines monitored by a heartbeat
+// watchdog. See Gcx.GoHeartbeat.
+type HeartbeatOpts struct {
+	// Name of the goroutine (see Gcx.GoNamed).
+	Name string
+	// Maximum time allowed between heartbeats. Must be positive.
+	Interval time.Duration
+	// If not zero, and less than Interval, Warn is called when
+	// the goroutine has been silent for WarnAfter. It is called
+	// at most once per silence period, from the watchdog
+	// goroutine.
+	WarnAfter time.Duration
+	Warn      func(name string, silent time.Duration)
+}
+
+// GoHeartbeat starts function f as a goroutine in context c, and a
+// watchdog that monitors it. Function f is passed a Heartbeat handle,
+// and must call Heartbeat.Beat at least once every o.Interval. If it
+// stays silent for longer, the watchdog kills c with exit status
+// *StallError, naming the goroutine. Killing c does not stop the
+// stalled goroutine, which must still return for c to terminate; a
+// goroutine blocked on I/O can be released, for example, by closing
+// the respective file or connection from an OnKill hook. The
+// watchdog is an additional goroutine of c that exits when f returns,
+// or when c is killed. If c is dead, GoHeartbeat panics, like Gcx.Go.
+func (c *Gcx) GoHeartbeat(o HeartbeatOpts, f func(hb *Heartbeat) error) {
+	if o.Interval <= 0 {
+		panic("Gcx.GoHeartbeat: interval must be positive")
+	}
+	loc := caller(0)
+	hb := &Heartbeat{}
+	hb.Beat()
+	done := make(chan struct{})
+	c.spawnAt(loc, o.Name, nil, func() error {
+		defer close(done)
+		return f(hb)
+	})
+	name := o.Name
+	if name == "" {
+		name = "heartbeat"
+	}
+	c.spawnAt(loc, name+" watchdog", nil, func() error {
+		return c.watchdog(hb, o, loc, done)
+	})
+}
+
+func (c *Gcx) watchdog(hb *Heartbeat, o HeartbeatOpts, loc errors.Location,
+	done <-chan struct{}) error {
+	warn := o.Warn != nil && o.WarnAfter > 0 && o.WarnAfter < o.Interval
+	kill := c.ChKill()
+	tm := time.NewTimer(o.Interval)
+	defer tm.Stop()
+	var warned time.Time // Last beat we have warned for
+	for {
+		last := hb.Last()
+		silent := time.Since(last)
+		if silent >= o.Interval {
+			err := &StallError{Name: o.Name, Loc: loc, Silent: silent}
+			c.killWith(err)
+			return err
+		}
+		next := o.Interval
+		if warn && !last.Equal(warned) {
+			if silent >= o.WarnAfter {
+				warned = last
+				o.Warn(o.Name, silent)
+			} else {
+				next = o.WarnAfter
+			}
+		}
+		tm.Reset(next - silent)
+		select {
+		case <-done:
+			return nil
+		case <-kill:
+			return ErrKilled
+		case <-tm.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestHeartbeatStall(t *testing.T) {
+	defer Track()()
+	var c Gcx
+	// Margins are wide: The watchdog must wake up (to warn)
+	// within Interval - WarnAfter of the time it is due.
+	warned := make(chan time.Duration, 1)
+	o := HeartbeatOpts{
+		Name:      "reader",
+		Interval:  500 * time.Millisecond,
+		WarnAfter: 50 * time.Millisecond,
+		Warn: func(name string, silent time.Duration) {
+			if name != "reader" {
+				t.Errorf("Warn: bad name %q", name)
+			}
+			warned <- silent
+		},
+	}
+	c.GoHeartbeat(o, func(hb *Heartbeat) error {
+		// Stall, until killed
+		<-c.ChKill()
+		return ErrKilled
+	})
+	e := c.Wait()
+	se, ok := e.(*StallError)
+	if !ok {
+		t.Fatalf("Wait: %v", e)
+	}
+	if !errors.IsTimeout(e) || se.Name != "reader" || se.Silent < o.Interval {
+		t.Fatalf("Bad error: %v", e)
+	}
+	if !strings.Contains(e.Error(), "heartbeat_test.go:") {
+		t.Fatalf("Bad error string: %v", e)
+	}
+	// The warning is issued before the kill, so it must have
+	// arrived by now; the deadline only guards against hangs.
+	select {
+	case s := <-warned:
+		if s < o.WarnAfter || s >= o.Interval {
+			t.Fatalf("Bad warning silence: %v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No warning")
+	}
+}
+
+func TestHeartbeatBeat(t *testing.T) {
+	var c Gcx
+	o := HeartbeatOpts{Interval: 50 * time.Millisecond}
+	c.GoHeartbeat(o, func(hb *Heartbeat) error {
+		for i := 0; i < 20; i++ {
+			time.Sleep(5 * time.Millisecond)
+			hb.Beat()
+		}
+		return nil
+	})
+	if e := c.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+}
+
+func TestHeartbeatKill(t *testing.T) {
+	var c Gcx
+	o := HeartbeatOpts{Interval: time.Second}
+	c.GoHeartbeat(o, func(hb *Heartbeat) error {
+		<-c.ChKill()
+		return ErrKilled
+	})
+	time.Sleep(10 * time.Millisecond)
+	if e := c.KillWait(); e != ErrKilled {
+		t.Fatalf("KillWait: %v", e)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import "time"
+
+// OnKill registers function f to be called when context c is killed
+// (when the channel returned by Gcx.ChKill is closed, for whatever
+// reason). Functions registered with OnKill are called once, in the
+// order they were registered, from the goroutine that killed the
+// context, and are passed the context's exit status at the time of
+// the kill (nil, if the context was killed with Gcx.Kill and no
+// goroutine had failed). If c is already killed, f is called
+// immediately. If c terminates without being killed, f is never
+// called. OnKill can be called for an empty context, in which case f
+// is called if and when the context, once started, is killed.
+func (c *Gcx) OnKill(f func(xs error)) {
+	c.mu.Lock()
+	if c.signaled {
+		xs := c.status
+		c.mu.Unlock()
+		f(xs)
+		return
+	}
+	c.onKill = append(c.onKill, f)
+	c.mu.Unlock()
+}
+
+// OnDead registers function f to be called when context c
+// terminates (becomes dead). Functions registered with OnDead are
+// called once, in the order they were registered, from the goroutine
+// that was the last to exit in the context, and are passed the
+// context's exit status. They are called before Gcx.Wait returns,
+// therefore they must not call Wait for c. If c is already dead, f is
+// called immediately. OnDead can be called for an empty context, in
+// which case f is called if and when the context, once started,
+// terminates.
+func (c *Gcx) OnDead(f func(xs error)) {
+	c.mu.Lock()
+	if c.ngort == -1 {
+		xs := c.status
+		c.mu.Unlock()
+		f(xs)
+		return
+	}
+	c.onDead = append(c.onDead, f)
+	c.mu.Unlock()
+}
+
+// Reset returns the dead context c to the empty state (the same
+// state as GcxZero), so that the same Gcx structure can be used to
+// start a new context. The group, the parent, the observer, the kill
+// policy, the scheduler, and the hooks of the context are also
+// cleared. If c is already empty, Reset does nothing. If c is
+// running, Reset returns ErrGcxNotEmpty.
+//
+// Reset must only be called once no-one uses the Gcx structure to
+// refer to the old context. If the context belongs to a group, its
+// exit status must have been retrieved from the group (see
+// Group.Wait) before it is reset.
+//
+// Reset can be called from an OnDead hook of c (e.g. in order to
+// restart the context, once the hook returns). In this case the
+// context is reset after all OnDead hooks have run, and after its
+// termination has been signaled, but before its parent and its group
+// are notified. Contexts reset this way must not be waited for (with
+// Gcx.Wait), and must not belong to a group.
+func (c *Gcx) Reset() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill == nil {
+		return nil
+	}
+	if c.ngort != -1 {
+		return ErrGcxNotEmpty
+	}
+	if c.exiting {
+		c.rstReq = true
+		return nil
+	}
+	c.reset()
+	return nil
+}
+
+// reset does the work for Reset. Must be called with c.mu held.
+func (c *Gcx) reset() {
+	// Keep c.tgen, so that stale deadline timers are ignored.
+	c.kill = nil
+	c.dead = nil
+	c.ngort = 0
+	c.signaled = false
+	c.drain = nil
+	c.draining = false
+	c.phase = PhaseRunning
+	c.status = nil
+	c.failed = false
+	c.reason = nil
+	c.group = nil
+	c.parent = nil
+	c.policy = 0
+	c.kids = nil
+	c.timer = nil
+	c.id = 0
+	c.pid = 0
+	c.born = time.Time{}
+	c.tracked = false
+	c.gors = nil
+	c.done = nil
+	c.onKill = nil
+	c.onDead = nil
+	c.obs = nil
+	c.kpol = nil
+	c.sched = nil
+	c.rstReq = false
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestHooks(t *testing.T) {
+	errFail := errors.New("failed")
+	var c Gcx
+	var calls []string
+	var killXs, deadXs error
+	c.OnKill(func(xs error) { calls = append(calls, "kill1"); killXs = xs })
+	c.OnDead(func(xs error) { calls = append(calls, "dead1"); deadXs = xs })
+	c.Go(waitKill(&c))
+	c.OnKill(func(xs error) { calls = append(calls, "kill2") })
+	c.OnDead(func(xs error) { calls = append(calls, "dead2") })
+	c.Go(func() error { return errFail })
+	if e := c.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+	want := []string{"kill1", "kill2", "dead1", "dead2"}
+	if len(calls) != len(want) {
+		t.Fatalf("Hook calls: %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("Hook calls: %v", calls)
+		}
+	}
+	if killXs != errFail || deadXs != errFail {
+		t.Fatalf("Hook status: %v, %v", killXs, deadXs)
+	}
+
+	// Registered after the fact
+	n := 0
+	c.OnKill(func(error) { n++ })
+	c.OnDead(func(error) { n++ })
+	if n != 2 {
+		t.Fatalf("Late hooks called %d times", n)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var c Gcx
+	if e := c.Reset(); e != nil {
+		t.Fatalf("Reset empty: %v", e)
+	}
+	c.Go(waitKill(&c))
+	if e := c.Reset(); e != ErrGcxNotEmpty {
+		t.Fatalf("Reset running: %v", e)
+	}
+	n := 0
+	c.OnDead(func(error) { n++ })
+	c.KillWait()
+	if e := c.Reset(); e != nil {
+		t.Fatalf("Reset dead: %v", e)
+	}
+	c.Go(func() error { return nil })
+	if e := c.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+	if n != 1 {
+		t.Fatalf("OnDead called %d times", n)
+	}
+}
+
+func TestResetOnDead(t *testing.T) {
+	errFail := errors.New("failed")
+	var p, c Gcx
+	var rst error
+	p.Go(waitKill(&p))
+	c.SetParent(&p, ChildKill)
+	c.OnDead(func(error) { rst = c.Reset() })
+	c.Go(func() error { return errFail })
+	// Parent is notified (and killed) after c is reset.
+	if e := p.Wait(); e != errFail {
+		t.Fatalf("Parent Wait: %v", e)
+	}
+	if rst != nil {
+		t.Fatalf("Reset from OnDead: %v", rst)
+	}
+	n := 0
+	c.OnDead(func(error) { n++ })
+	c.Go(func() error { return nil })
+	if e := c.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+	if n != 1 {
+		t.Fatalf("OnDead called %d times", n)
+	}
+}
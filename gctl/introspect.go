@@ -0,0 +1,313 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// GoState is the state of a goroutine started with Gcx.Go (or
+// Gcx.GoNamed), as reported by Snapshot.
+type GoState int
+
+// Goroutine states
+const (
+	GoRunning GoState = iota // Still running
+	GoDone                   // Finished with nil status
+	GoFailed                 // Finished with non-nil status
+)
+
+var goStateNames = [...]string{
+	GoRunning: "running",
+	GoDone:    "done",
+	GoFailed:  "failed",
+}
+
+func (s GoState) String() string {
+	if s < 0 || int(s) >= len(goStateNames) {
+		return fmt.Sprintf("GoState(%d)", int(s))
+	}
+	return goStateNames[s]
+}
+
+// GoInfo describes a goroutine started with Gcx.Go or Gcx.GoNamed.
+type GoInfo struct {
+	Name  string          // As given to GoNamed, or empty
+	Loc   errors.Location // Where Go / GoNamed was called from, if tracked
+	Start time.Time       // When the goroutine was started
+	State GoState         // Goroutine state
+	Err   error           // Exit status, if finished
+}
+
+// GcxInfo describes a live (running) goroutine context.
+type GcxInfo struct {
+	ID       uint64    // Unique context id
+	ParentID uint64    // Id of parent context, or zero
+	Start    time.Time // When the context was started
+	Killed   bool      // Kill channel closed?
+	Children int       // Number of active child contexts
+	// Running goroutines, followed by the most recently finished
+	// ones (at most KeepFinished of them).
+	Goroutines []GoInfo
+}
+
+// KeepFinished is the maximum number of finished goroutines
+// remembered, per context, for reporting by Snapshot.
+const KeepFinished = 16
+
+// tracking is the number of calls to Track not yet undone.
+var tracking int32
+
+// Track enables tracking of goroutine contexts: Contexts started
+// while tracking is enabled are added to the registry of live
+// contexts (see Snapshot, Lookup, Dump), and their goroutines are
+// recorded, along with their start locations. Since this adds cost
+// to every Gcx.Go call, tracking is disabled by default, and should
+// only be enabled by the consumers of this information (e.g. a debug
+// handler, or a leak checker). Track returns a function that undoes
+// the call. Calls to Track nest: tracking remains enabled until all
+// of them are undone.
+func Track() (untrack func()) {
+	atomic.AddInt32(&tracking, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt32(&tracking, -1) })
+	}
+}
+
+// caller returns the location of the caller of the function calling
+// caller, skipping skip additional stack frames, if tracking is
+// enabled. Otherwise it returns the zero Location.
+func caller(skip int) errors.Location {
+	var loc errors.Location
+	if atomic.LoadInt32(&tracking) > 0 {
+		loc.Set(skip + 2)
+	}
+	return loc
+}
+
+// registry keeps track of all live, tracked contexts.
+var registry struct {
+	mu   sync.Mutex
+	live map[*Gcx]struct{}
+}
+
+var lastID uint64
+
+// register assigns an id to c and, if tracking is enabled, adds it to
+// the registry of live contexts. Must be called with c.mu held.
+func (c *Gcx) register() {
+	c.id = atomic.AddUint64(&lastID, 1)
+	c.born = time.Now()
+	c.tracked = atomic.LoadInt32(&tracking) > 0
+	if !c.tracked {
+		return
+	}
+	c.gors = make(map[*GoInfo]struct{})
+	registry.mu.Lock()
+	if registry.live == nil {
+		registry.live = make(map[*Gcx]struct{})
+	}
+	registry.live[c] = struct{}{}
+	registry.mu.Unlock()
+}
+
+// unregister removes c from the registry of live contexts. Must be
+// called with c.mu held.
+func (c *Gcx) unregister() {
+	if !c.tracked {
+		return
+	}
+	registry.mu.Lock()
+	delete(registry.live, c)
+	registry.mu.Unlock()
+}
+
+// retire moves goroutine record r from the running to the finished
+// goroutines of c. Must be called with c.mu held.
+func (c *Gcx) retire(r *GoInfo, err error) {
+	delete(c.gors, r)
+	r.Err = err
+	if err != nil {
+		r.State = GoFailed
+	} else {
+		r.State = GoDone
+	}
+	if len(c.done) == KeepFinished {
+		copy(c.done, c.done[1:])
+		c.done = c.done[:KeepFinished-1]
+	}
+	c.done = append(c.done, *r)
+}
+
+// info returns the description of context c. It returns false if c
+// is no longer live.
+func (c *Gcx) info() (GcxInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill == nil || c.ngort == -1 {
+		return GcxInfo{}, false
+	}
+	ci := GcxInfo{
+		ID:       c.id,
+		Start:    c.born,
+		Killed:   c.signaled,
+		ParentID: c.pid,
+		Children: len(c.kids),
+	}
+	ci.Goroutines = make([]GoInfo, 0, len(c.gors)+len(c.done))
+	for r := range c.gors {
+		ci.Goroutines = append(ci.Goroutines, *r)
+	}
+	sort.Slice(ci.Goroutines, func(i, j int) bool {
+		return ci.Goroutines[i].Start.Before(ci.Goroutines[j].Start)
+	})
+	ci.Goroutines = append(ci.Goroutines, c.done...)
+	return ci, true
+}
+
+// Info returns the description of context c. It returns false if c
+// is not live (if it is empty or dead). The goroutines of c are only
+// described if c was started while tracking was enabled (see Track).
+func (c *Gcx) Info() (GcxInfo, bool) {
+	return c.info()
+}
+
+// LastID returns the id of the most recently started context. Since
+// ids are assigned in increasing order, contexts started after a call
+// to LastID have ids greater than the value it returned.
+func LastID() uint64 {
+	return atomic.LoadUint64(&lastID)
+}
+
+// Lookup returns the live context with the given id, or nil if there
+// is no such context, or if it is not tracked (see Track).
+func Lookup(id uint64) *Gcx {
+	registry.mu.Lock()
+	cs := make([]*Gcx, 0, len(registry.live))
+	for c := range registry.live {
+		cs = append(cs, c)
+	}
+	registry.mu.Unlock()
+	for _, c := range cs {
+		c.mu.Lock()
+		found := c.id == id && c.ngort != -1
+		c.mu.Unlock()
+		if found {
+			return c
+		}
+	}
+	return nil
+}
+
+// Snapshot returns the descriptions of all live contexts started
+// while tracking was enabled (see Track), ordered by id.
+func Snapshot() []GcxInfo {
+	registry.mu.Lock()
+	cs := make([]*Gcx, 0, len(registry.live))
+	for c := range registry.live {
+		cs = append(cs, c)
+	}
+	registry.mu.Unlock()
+
+	ci := make([]GcxInfo, 0, len(cs))
+	for _, c := range cs {
+		if i, ok := c.info(); ok {
+			ci = append(ci, i)
+		}
+	}
+	sort.Slice(ci, func(i, j int) bool { return ci[i].ID < ci[j].ID })
+	return ci
+}
+
+// Dump writes a human-readable description of all live contexts
+// (see Snapshot) to w.
+func Dump(w io.Writer) error {
+	now := time.Now()
+	for _, c := range Snapshot() {
+		s := fmt.Sprintf("gcx %d", c.ID)
+		if c.ParentID != 0 {
+			s += fmt.Sprintf(" (parent %d)", c.ParentID)
+		}
+		if c.Killed {
+			s += " killed"
+		}
+		s += fmt.Sprintf(", up %v, %d children\n",
+			now.Sub(c.Start), c.Children)
+		for _, g := range c.Goroutines {
+			s += "\t"
+			if g.Name != "" {
+				s += g.Name + " "
+			}
+			s += fmt.Sprintf("[%s] %v, started %v ago",
+				g.Loc, g.State, now.Sub(g.Start))
+			if g.Err != nil {
+				s += ": " + g.Err.Error()
+			}
+			s += "\n"
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonGoInfo struct {
+	Name  string    `json:"name,omitempty"`
+	Loc   string    `json:"loc"`
+	Start time.Time `json:"start"`
+	State string    `json:"state"`
+	Err   string    `json:"err,omitempty"`
+}
+
+type jsonGcxInfo struct {
+	ID         uint64       `json:"id"`
+	ParentID   uint64       `json:"parent,omitempty"`
+	Start      time.Time    `json:"start"`
+	Killed     bool         `json:"killed"`
+	Children   int          `json:"children"`
+	Goroutines []jsonGoInfo `json:"goroutines"`
+}
+
+// DumpJSON writes the descriptions of all live contexts (see
+// Snapshot) to w, encoded as a JSON array.
+func DumpJSON(w io.Writer) error {
+	ss := Snapshot()
+	js := make([]jsonGcxInfo, 0, len(ss))
+	for _, c := range ss {
+		jc := jsonGcxInfo{
+			ID:         c.ID,
+			ParentID:   c.ParentID,
+			Start:      c.Start,
+			Killed:     c.Killed,
+			Children:   c.Children,
+			Goroutines: make([]jsonGoInfo, 0, len(c.Goroutines)),
+		}
+		for _, g := range c.Goroutines {
+			jg := jsonGoInfo{
+				Name:  g.Name,
+				Loc:   g.Loc.String(),
+				Start: g.Start,
+				State: g.State.String(),
+			}
+			if g.Err != nil {
+				jg.Err = g.Err.Error()
+			}
+			jc.Goroutines = append(jc.Goroutines, jg)
+		}
+		js = append(js, jc)
+	}
+	return json.NewEncoder(w).Encode(js)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestSnapshot(t *testing.T) {
+	defer Track()()
+	errFail := errors.New("failed")
+	var c Gcx
+	// Failer kills the context; waiter keeps it alive until
+	// released.
+	release := make(chan struct{})
+	c.GoNamed("waiter", func() error {
+		<-release
+		return nil
+	})
+	c.GoNamed("quitter", func() error { return nil })
+	c.GoNamed("failer", func() error { return errFail })
+
+	// Wait for quitter and failer to finish
+	deadline := time.Now().Add(time.Second)
+	for ; ; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for goroutines to finish")
+		}
+		ci, ok := c.Info()
+		if !ok {
+			t.Fatal("Gcx.Info: context not live")
+		}
+		if len(ci.Goroutines) == 3 && ci.Goroutines[0].State == GoRunning &&
+			ci.Goroutines[1].State != GoRunning &&
+			ci.Goroutines[2].State != GoRunning {
+			break
+		}
+	}
+
+	var ci GcxInfo
+	for _, i := range Snapshot() {
+		if i.ID == c.id {
+			ci = i
+		}
+	}
+	if ci.ID == 0 {
+		t.Fatal("Snapshot: context not found")
+	}
+	g := ci.Goroutines[0]
+	if g.Name != "waiter" || g.State != GoRunning ||
+		!strings.HasSuffix(g.Loc.File, "introspect_test.go") {
+		t.Fatalf("Bad goroutine info: %+v", g)
+	}
+	for _, g := range ci.Goroutines[1:] {
+		if g.Name == "failer" && (g.State != GoFailed || g.Err != errFail) {
+			t.Fatalf("Bad goroutine info: %+v", g)
+		}
+	}
+
+	var b bytes.Buffer
+	Dump(&b)
+	if !strings.Contains(b.String(), "waiter [gctl/introspect_test.go:") {
+		t.Fatalf("Bad dump: %s", b.String())
+	}
+
+	b.Reset()
+	DumpJSON(&b)
+	var js []jsonGcxInfo
+	if err := json.Unmarshal(b.Bytes(), &js); err != nil {
+		t.Fatalf("Bad JSON: %v", err)
+	}
+
+	close(release)
+	c.Wait()
+	if _, ok := c.Info(); ok {
+		t.Fatal("Gcx.Info: context live after termination")
+	}
+	for _, i := range Snapshot() {
+		if i.ID == ci.ID {
+			t.Fatal("Snapshot: dead context reported")
+		}
+	}
+}
+
+func TestTrack(t *testing.T) {
+	untrack := Track()
+	untrack()
+	untrack() // No effect
+	var c Gcx
+	c.GoNamed("waiter", waitKill(&c))
+	ci, ok := c.Info()
+	if !ok || ci.ID == 0 || len(ci.Goroutines) != 0 {
+		t.Fatalf("Gcx.Info: %+v, %v", ci, ok)
+	}
+	if Lookup(ci.ID) != nil {
+		t.Fatal("Lookup: untracked context found")
+	}
+	c.Kill()
+	c.Wait()
+
+	var k Gcx
+	k.Go(func() error { return errors.New("fail") })
+	k.Wait()
+	if fe, ok := k.Reason().(*FailError); !ok || fe.Loc.IsSet() {
+		t.Fatalf("Reason: %#v", k.Reason())
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// ErrRateLimited is returned by Limiter.TryGo if no token is
+// available. It tests true with errors.IsTemporary().
+var ErrRateLimited = errors.ErrNL(errors.ErrTemporary, "Gcx rate limited")
+
+// Limiter limits the rate at which goroutines are started in a
+// context, using a token bucket: The bucket holds up to burst tokens,
+// and is refilled at rate tokens per second. Each goroutine started
+// through the limiter consumes a token. The rate and the burst can be
+// changed at any time.
+type Limiter struct {
+	c       *Gcx
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	tokens  float64
+	last    time.Time     // last refill
+	changed chan struct{} // closed when rate or burst changes
+}
+
+// NewLimiter returns a limiter for starting goroutines in context c,
+// at rate tokens per second, with bursts of up to burst goroutines.
+// The bucket starts full. A zero rate means that the bucket is never
+// refilled.
+func NewLimiter(c *Gcx, rate float64, burst int) *Limiter {
+	return &Limiter{
+		c:       c,
+		rate:    rate,
+		burst:   burst,
+		tokens:  float64(burst),
+		last:    time.Now(),
+		changed: make(chan struct{}),
+	}
+}
+
+// refill adds the tokens accumulated since the last refill. Must be
+// called with l.mu held.
+func (l *Limiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+	}
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+}
+
+// take takes a token, if one is available. Otherwise it returns the
+// time until one becomes available (or a negative duration if none
+// will ever be), and a channel that is closed if the limiter's
+// parameters change in the meantime.
+func (l *Limiter) take() (ok bool, wait time.Duration, changed <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0, nil
+	}
+	if l.rate <= 0 || l.burst < 1 {
+		return false, -1, l.changed
+	}
+	wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	return false, wait, l.changed
+}
+
+// Go waits for a token, and then runs function f as a goroutine in
+// the limiter's context, like Gcx.Go. While waiting, the context is
+// kept alive (and is started, if empty). If the context is killed
+// while waiting, Go returns ErrKilled, and f is not run. If the
+// context is dead, Go panics, like Gcx.Go, without taking a token.
+func (l *Limiter) Go(f func() error) error {
+	loc := caller(0)
+	l.c.hold()
+	defer l.c.exit(nil, nil, nil)
+	kill := l.c.ChKill()
+	for {
+		ok, wait, changed := l.take()
+		if ok {
+			break
+		}
+		var tm *time.Timer
+		var tc <-chan time.Time
+		if wait >= 0 {
+			tm = time.NewTimer(wait)
+			tc = tm.C
+		}
+		var killed bool
+		select {
+		case <-kill:
+			killed = true
+		case <-changed:
+		case <-tc:
+		}
+		if tm != nil {
+			tm.Stop()
+		}
+		if killed {
+			return ErrKilled
+		}
+	}
+	l.c.spawnAt(loc, "", nil, f)
+	return nil
+}
+
+// TryGo is like Limiter.Go, but does not wait. If no token is
+// available it returns ErrRateLimited, and f is not run.
+func (l *Limiter) TryGo(f func() error) error {
+	loc := caller(0)
+	l.c.hold()
+	defer l.c.exit(nil, nil, nil)
+	if ok, _, _ := l.take(); !ok {
+		return ErrRateLimited
+	}
+	l.c.spawnAt(loc, "", nil, f)
+	return nil
+}
+
+// set updates the limiter's parameters, using function f, and wakes
+// up the goroutines waiting for tokens.
+func (l *Limiter) set(f func()) {
+	l.mu.Lock()
+	l.refill(time.Now())
+	f()
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// SetRate sets the refill rate of the limiter, in tokens per second.
+func (l *Limiter) SetRate(rate float64) {
+	l.set(func() { l.rate = rate })
+}
+
+// SetBurst sets the maximum number of tokens in the bucket.
+func (l *Limiter) SetBurst(burst int) {
+	l.set(func() { l.burst = burst })
+}
+
+// Rate returns the refill rate of the limiter, in tokens per second.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Burst returns the maximum number of tokens in the bucket.
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestLimiter(t *testing.T) {
+	var c Gcx
+	c.Go(waitKill(&c))
+	nop := func() error { return nil }
+	l := NewLimiter(&c, 100, 3)
+	for i := 0; i < 3; i++ {
+		if e := l.TryGo(nop); e != nil {
+			t.Fatalf("TryGo %d: %v", i, e)
+		}
+	}
+	if e := l.TryGo(nop); e != ErrRateLimited || !errors.IsTemporary(e) {
+		t.Fatalf("TryGo: %v", e)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if e := l.Go(nop); e != nil {
+			t.Fatalf("Go %d: %v", i, e)
+		}
+	}
+	if d := time.Since(start); d < 25*time.Millisecond {
+		t.Fatalf("Go not rate-limited: %v", d)
+	}
+	c.KillWait()
+}
+
+func TestLimiterKill(t *testing.T) {
+	var c Gcx
+	c.Go(waitKill(&c))
+	l := NewLimiter(&c, 0, 0)
+	time.AfterFunc(10*time.Millisecond, func() { c.Kill() })
+	if e := l.Go(func() error { return nil }); e != ErrKilled {
+		t.Fatalf("Go: %v", e)
+	}
+	c.Wait()
+}
+
+func TestLimiterEmpty(t *testing.T) {
+	var c Gcx
+	l := NewLimiter(&c, 0, 0)
+	done := make(chan error)
+	go func() { done <- l.Go(func() error { return nil }) }()
+	// Go starts the context while waiting
+	deadline := time.Now().Add(time.Second)
+	for ; ; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Context not started")
+		}
+		if _, ok := c.Info(); ok {
+			break
+		}
+	}
+	c.Kill()
+	select {
+	case e := <-done:
+		if e != ErrKilled {
+			t.Fatalf("Go: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Go not woken by Kill")
+	}
+	if e := c.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+}
+
+func TestLimiterDead(t *testing.T) {
+	var c Gcx
+	c.Go(func() error { return nil })
+	c.Wait()
+	l := NewLimiter(&c, 0, 1)
+	for _, g := range []func(func() error) error{l.Go, l.TryGo} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("No panic for dead context")
+				}
+			}()
+			g(func() error { return nil })
+		}()
+		if l.tokens != 1 {
+			t.Fatalf("Token taken: %v", l.tokens)
+		}
+	}
+}
+
+func TestLimiterSet(t *testing.T) {
+	var c Gcx
+	c.Go(waitKill(&c))
+	l := NewLimiter(&c, 0, 0)
+	done := make(chan error)
+	go func() { done <- l.Go(func() error { return nil }) }()
+	time.Sleep(10 * time.Millisecond)
+	l.SetBurst(1)
+	l.SetRate(1000)
+	if l.Rate() != 1000 || l.Burst() != 1 {
+		t.Fatalf("Rate %v, Burst %d", l.Rate(), l.Burst())
+	}
+	select {
+	case e := <-done:
+		if e != nil {
+			t.Fatalf("Go: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Go not woken by SetRate")
+	}
+	c.KillWait()
+}
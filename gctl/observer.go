@@ -0,0 +1,239 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Observer is the interface implemented by types that wish to be
+// notified about the activity of goroutine contexts, e.g. for
+// collecting metrics or for tracing. Contexts are identified by their
+// unique id (see GcxInfo). Observer methods may be called
+// concurrently from multiple goroutines, and GoStart is called with
+// the context's internal lock held; therefore observer methods must
+// not block, and must not call any methods of the context.
+type Observer interface {
+	// GoStart is called when a goroutine is started in context
+	// id.
+	GoStart(id uint64, g GoInfo)
+	// GoEnd is called when a goroutine of context id exits. The
+	// goroutine's exit status is in g.Err, and d is the time it
+	// run for.
+	GoEnd(id uint64, g GoInfo, d time.Duration)
+	// Kill is called when context id is killed. Argument xs is
+	// the context's exit status at the time of the kill (see
+	// Gcx.OnKill).
+	Kill(id uint64, xs error)
+	// Dead is called when context id terminates, with the
+	// context's exit status and the time the context was alive.
+	Dead(id uint64, xs error, d time.Duration)
+}
+
+var defObserver struct {
+	mu  sync.Mutex
+	obs Observer
+}
+
+// SetDefaultObserver sets the observer used by contexts for which no
+// other observer is set (see Gcx.SetObserver). A nil o removes the
+// default observer. It affects contexts started after the call.
+func SetDefaultObserver(o Observer) {
+	defObserver.mu.Lock()
+	defObserver.obs = o
+	defObserver.mu.Unlock()
+}
+
+// SetObserver sets the observer for context c. Like Gcx.SetGroup, it
+// must be called before the context is started, otherwise it panics.
+// If no observer is set for a context, the observer of its group is
+// used; if the group has no observer either, the observer of its
+// parent context is used; otherwise the default observer (see
+// SetDefaultObserver).
+func (c *Gcx) SetObserver(o Observer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill != nil {
+		panic("Gcx.SetObserver: Gcx context not empty")
+	}
+	c.obs = o
+}
+
+// SetObserver sets the observer for the contexts of group g that
+// have no observer of their own. It affects contexts started after
+// the call.
+func (g *Group) SetObserver(o Observer) {
+	g.mu.Lock()
+	g.obs = o
+	g.mu.Unlock()
+}
+
+// observe resolves the observer of c, as it starts, and arranges for
+// it to be notified of the context's kill and death. Must be called
+// with c.mu held.
+func (c *Gcx) observe() {
+	if c.obs == nil && c.group != nil {
+		c.group.mu.Lock()
+		c.obs = c.group.obs
+		c.group.mu.Unlock()
+	}
+	if c.obs == nil && c.parent != nil {
+		c.parent.mu.Lock()
+		c.obs = c.parent.obs
+		c.parent.mu.Unlock()
+	}
+	if c.obs == nil {
+		defObserver.mu.Lock()
+		c.obs = defObserver.obs
+		defObserver.mu.Unlock()
+	}
+	if c.obs == nil {
+		return
+	}
+	o, id, born := c.obs, c.id, c.born
+	c.onKill = append([]func(error){func(xs error) {
+		o.Kill(id, xs)
+	}}, c.onKill...)
+	c.onDead = append([]func(error){func(xs error) {
+		o.Dead(id, xs, time.Since(born))
+	}}, c.onDead...)
+}
+
+// HistBuckets is the number of buckets in the duration histograms of
+// HistObserver. Bucket i counts durations less than 1us * 2^i (and
+// not counted by a lower bucket); the last bucket counts all longer
+// durations.
+const HistBuckets = 32
+
+// Hist is a histogram of durations. See HistBuckets.
+type Hist [HistBuckets]uint64
+
+func (h *Hist) add(d time.Duration) {
+	i := 0
+	for lim := time.Microsecond; i < HistBuckets-1 && d >= lim; lim *= 2 {
+		i++
+	}
+	h[i]++
+}
+
+// Upper returns the upper bound of bucket i of the histogram. For the
+// last bucket it returns zero.
+func (h *Hist) Upper(i int) time.Duration {
+	if i >= HistBuckets-1 {
+		return 0
+	}
+	return time.Microsecond << uint(i)
+}
+
+// HistStats is a snapshot of the statistics collected by a
+// HistObserver.
+type HistStats struct {
+	Started   uint64         // Goroutines started
+	Failed    uint64         // Goroutines exited with non-nil status
+	Running   int            // Goroutines running
+	Killed    uint64         // Contexts killed
+	Dead      uint64         // Contexts terminated
+	GoTime    Hist           // Goroutine run times
+	GcxTime   Hist           // Context lifetimes
+	PerGcx    map[uint64]int // Running goroutines, per live context
+	FailedGcx uint64         // Contexts terminated with non-nil status
+}
+
+// HistObserver is an Observer that keeps in-memory counters and
+// histograms of goroutine and context activity. It is suitable for
+// use in tests and for debugging endpoints. The zero value is ready
+// to use.
+type HistObserver struct {
+	mu sync.Mutex
+	st HistStats
+}
+
+// GoStart implements Observer. It counts the goroutine as started,
+// and as running in context id.
+func (h *HistObserver) GoStart(id uint64, g GoInfo) {
+	h.mu.Lock()
+	if h.st.PerGcx == nil {
+		h.st.PerGcx = make(map[uint64]int)
+	}
+	h.st.Started++
+	h.st.Running++
+	h.st.PerGcx[id]++
+	h.mu.Unlock()
+}
+
+// GoEnd implements Observer. It records the goroutine's run time.
+func (h *HistObserver) GoEnd(id uint64, g GoInfo, d time.Duration) {
+	h.mu.Lock()
+	if g.Err != nil {
+		h.st.Failed++
+	}
+	h.st.Running--
+	h.st.PerGcx[id]--
+	h.st.GoTime.add(d)
+	h.mu.Unlock()
+}
+
+// Kill implements Observer.
+func (h *HistObserver) Kill(id uint64, xs error) {
+	h.mu.Lock()
+	h.st.Killed++
+	h.mu.Unlock()
+}
+
+// Dead implements Observer. It records the context's lifetime.
+func (h *HistObserver) Dead(id uint64, xs error, d time.Duration) {
+	h.mu.Lock()
+	h.st.Dead++
+	if xs != nil {
+		h.st.FailedGcx++
+	}
+	delete(h.st.PerGcx, id)
+	h.st.GcxTime.add(d)
+	h.mu.Unlock()
+}
+
+// Stats returns a snapshot of the statistics collected by h.
+func (h *HistObserver) Stats() HistStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.st
+	st.PerGcx = make(map[uint64]int, len(h.st.PerGcx))
+	for id, n := range h.st.PerGcx {
+		st.PerGcx[id] = n
+	}
+	return st
+}
+
+// Dump writes a human-readable form of the statistics collected by h
+// to w.
+func (h *HistObserver) Dump(w io.Writer) error {
+	st := h.Stats()
+	s := fmt.Sprintf("goroutines: %d started, %d running, %d failed\n",
+		st.Started, st.Running, st.Failed)
+	s += fmt.Sprintf("contexts: %d live, %d killed, %d dead, %d failed\n",
+		len(st.PerGcx), st.Killed, st.Dead, st.FailedGcx)
+	for _, hs := range []struct {
+		name string
+		h    *Hist
+	}{{"goroutine run time", &st.GoTime}, {"context lifetime", &st.GcxTime}} {
+		s += hs.name + ":\n"
+		for i, n := range hs.h {
+			if n == 0 {
+				continue
+			}
+			if u := hs.h.Upper(i); u != 0 {
+				s += fmt.Sprintf("\t< %v: %d\n", u, n)
+			} else {
+				s += fmt.Sprintf("\t>= %v: %d\n", hs.h.Upper(i-1), n)
+			}
+		}
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestHistObserver(t *testing.T) {
+	var h HistObserver
+	var g Group
+	g.SetObserver(&h)
+
+	var c Gcx
+	c.SetGroup(&g)
+	c.Go(waitKill(&c))
+	c.Go(func() error { return nil })
+	var k Gcx
+	k.SetParent(&c, ChildIgnore)
+	k.Go(func() error { return errors.New("failed") })
+	k.Wait()
+
+	st := h.Stats()
+	deadline := time.Now().Add(time.Second)
+	for st.Running != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for goroutines: %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+		st = h.Stats()
+	}
+	if st.Started != 3 || st.Failed != 1 || st.Dead != 1 ||
+		st.FailedGcx != 1 || st.PerGcx[c.id] != 1 {
+		t.Fatalf("Bad stats: %+v", st)
+	}
+	g.KillWaitAll()
+	st = h.Stats()
+	if st.Running != 0 || st.Killed != 2 || st.Dead != 2 ||
+		len(st.PerGcx) != 0 {
+		t.Fatalf("Bad stats: %+v", st)
+	}
+	var n uint64
+	for _, x := range st.GoTime {
+		n += x
+	}
+	if n != 3 {
+		t.Fatalf("Bad histogram: %v", st.GoTime)
+	}
+
+	var b bytes.Buffer
+	h.Dump(&b)
+	if !strings.HasPrefix(b.String(), "goroutines: 3 started") {
+		t.Fatalf("Bad dump: %s", b.String())
+	}
+}
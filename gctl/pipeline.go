@@ -0,0 +1,202 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import "sync/atomic"
+
+// PipePolicy determines what happens to the items that are in flight
+// (being processed by stages, or buffered between them) when a
+// Pipeline is killed.
+type PipePolicy int
+
+// Pipeline kill policies
+const (
+	// PipeDrop: All stages stop as soon as possible, and in-flight
+	// items are dropped.
+	PipeDrop PipePolicy = iota
+	// PipeDrain: The source stops emitting, but in-flight items
+	// continue to be processed by the remaining stages until the
+	// pipeline is empty. Items reaching a stage that has failed
+	// are dropped.
+	PipeDrain
+)
+
+// Emit is the function a pipeline stage calls to pass an item to the
+// next stage. It blocks until the next stage accepts the item. It
+// returns ErrKilled if the item could not be passed because the
+// pipeline was killed; the stage should then return.
+type Emit func(v interface{}) error
+
+type pipeStage struct {
+	name string
+	n    int
+	buf  int
+	src  func(emit Emit) error
+	f    func(v interface{}, emit Emit) error
+}
+
+// Pipeline is a chain of stages connected with channels. The first
+// stage is the source that produces items, each subsequent stage
+// receives items from the previous one, processes them, and passes
+// (zero or more) results to the next. Each stage runs in a number of
+// goroutines, all in the pipeline's embedded Gcx. When all goroutines
+// of a stage exit, the channel to the next stage is closed, so that
+// the pipeline shuts down in order, from the source to the last
+// stage, once the source is exhausted.
+//
+// If any stage returns an error (other than ErrKilled), the pipeline
+// is killed and the error becomes its exit status. Policy determines
+// what happens to in-flight items when the pipeline is killed.
+//
+// A pipeline is built by calling Pipeline.Source once, followed by
+// any number of calls to Pipeline.Stage and, optionally, a call to
+// Pipeline.Sink, and is then started with Pipeline.Start. Items
+// emitted by the last stage, if it is not a sink, are dropped.
+type Pipeline struct {
+	Gcx
+	Policy PipePolicy
+	stages []*pipeStage
+}
+
+func (p *Pipeline) add(s *pipeStage) {
+	if len(p.stages) == 0 && s.src == nil {
+		panic("Pipeline.Stage: pipeline has no source")
+	}
+	if len(p.stages) != 0 && s.src != nil {
+		panic("Pipeline.Source: pipeline already has a source")
+	}
+	p.stages = append(p.stages, s)
+}
+
+// Source sets the source stage of the pipeline. Function f is run
+// in a single goroutine, and calls emit to produce items. The source
+// is exhausted when f returns.
+func (p *Pipeline) Source(name string, f func(emit Emit) error) {
+	p.add(&pipeStage{name: name, n: 1, src: f})
+}
+
+// Stage appends a stage to the pipeline. The stage runs in n
+// goroutines, each calling f for every item received from the
+// previous stage. Function f calls emit to pass results to the next
+// stage. The channel from the previous stage to this one can buffer
+// up to buf items. If n is less than 1, Stage panics.
+func (p *Pipeline) Stage(name string, n, buf int,
+	f func(v interface{}, emit Emit) error) {
+	if n < 1 {
+		panic("Pipeline.Stage: number of goroutines must be at least 1")
+	}
+	p.add(&pipeStage{name: name, n: n, buf: buf, f: f})
+}
+
+// Sink appends the final stage to the pipeline. It is like
+// Pipeline.Stage, except that f produces no items.
+func (p *Pipeline) Sink(name string, n, buf int, f func(v interface{}) error) {
+	p.Stage(name, n, buf, func(v interface{}, _ Emit) error {
+		return f(v)
+	})
+}
+
+// Start starts all the stages of the pipeline.
+func (p *Pipeline) Start() {
+	if len(p.stages) == 0 {
+		panic("Pipeline.Start: pipeline has no source")
+	}
+	chs := make([]chan interface{}, len(p.stages)+1)
+	for i := 1; i < len(p.stages); i++ {
+		chs[i] = make(chan interface{}, p.stages[i].buf)
+	}
+	for i, s := range p.stages {
+		p.startStage(s, chs[i], chs[i+1])
+	}
+}
+
+// Run starts the pipeline and waits for it to terminate. It returns
+// the pipeline's exit status.
+func (p *Pipeline) Run() error {
+	p.Start()
+	return p.Wait()
+}
+
+func (p *Pipeline) startStage(s *pipeStage, in <-chan interface{},
+	out chan<- interface{}) {
+	left := int32(s.n)
+	for i := 0; i < s.n; i++ {
+		p.GoNamed(s.name, func() error {
+			defer func() {
+				if atomic.AddInt32(&left, -1) == 0 && out != nil {
+					close(out)
+				}
+			}()
+			if s.src != nil {
+				return p.fail(s.src(p.emitter(out, true)))
+			}
+			return p.runStage(s, in, p.emitter(out, false))
+		})
+	}
+}
+
+// fail kills the pipeline if err is a stage failure. It returns err.
+func (p *Pipeline) fail(err error) error {
+	if err != nil && err != ErrKilled {
+		p.killWith(err)
+	}
+	return err
+}
+
+func (p *Pipeline) emitter(out chan<- interface{}, src bool) Emit {
+	kill := p.ChKill()
+	if out == nil {
+		return func(v interface{}) error { return nil }
+	}
+	if p.Policy == PipeDrain && !src {
+		return func(v interface{}) error {
+			out <- v
+			return nil
+		}
+	}
+	return func(v interface{}) error {
+		select {
+		case out <- v:
+			return nil
+		case <-kill:
+			return ErrKilled
+		}
+	}
+}
+
+func (p *Pipeline) runStage(s *pipeStage, in <-chan interface{},
+	emit Emit) error {
+	kill := p.ChKill()
+	if p.Policy == PipeDrain {
+		// Keep consuming, even after failure, so that the
+		// previous stages can drain.
+		var xs error
+		for v := range in {
+			if xs != nil {
+				continue
+			}
+			if err := p.fail(s.f(v, emit)); err != nil {
+				xs = err
+			}
+		}
+		return xs
+	}
+	for {
+		var v interface{}
+		var ok bool
+		select {
+		case v, ok = <-in:
+			if !ok {
+				return nil
+			}
+		case <-kill:
+			return ErrKilled
+		}
+		if err := p.fail(s.f(v, emit)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func counter(n int) func(emit Emit) error {
+	return func(emit Emit) error {
+		for i := 0; i < n; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	var mu sync.Mutex
+	sum := 0
+	p := &Pipeline{}
+	p.Source("count", counter(100))
+	p.Stage("double", 4, 2, func(v interface{}, emit Emit) error {
+		return emit(v.(int) * 2)
+	})
+	p.Stage("odd", 2, 0, func(v interface{}, emit Emit) error {
+		if v.(int)%4 == 0 {
+			return nil
+		}
+		return emit(v)
+	})
+	p.Sink("sum", 3, 1, func(v interface{}) error {
+		mu.Lock()
+		sum += v.(int)
+		mu.Unlock()
+		return nil
+	})
+	if e := p.Run(); e != nil {
+		t.Fatalf("Run: %v", e)
+	}
+	if sum != 5000 {
+		t.Fatalf("sum = %d", sum)
+	}
+}
+
+func TestPipelineFail(t *testing.T) {
+	errBad := errors.New("bad item")
+	for _, pol := range []PipePolicy{PipeDrop, PipeDrain} {
+		var mu sync.Mutex
+		n := 0
+		p := &Pipeline{Policy: pol}
+		p.Source("count", func(emit Emit) error {
+			for i := 0; ; i++ {
+				if err := emit(i); err != nil {
+					return err
+				}
+			}
+		})
+		p.Stage("check", 2, 4, func(v interface{}, emit Emit) error {
+			if v.(int) == 10 {
+				return errBad
+			}
+			return emit(v)
+		})
+		p.Sink("count", 1, 4, func(v interface{}) error {
+			mu.Lock()
+			n++
+			mu.Unlock()
+			return nil
+		})
+		if e := p.Run(); e != errBad {
+			t.Fatalf("policy %d: Run: %v", pol, e)
+		}
+		if n > 20 {
+			t.Fatalf("policy %d: %d items reached sink", pol, n)
+		}
+	}
+}
+
+func TestPipelineStageArgs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Pipeline.Stage: No panic for zero goroutines")
+		}
+	}()
+	p := &Pipeline{}
+	p.Source("count", func(emit Emit) error { return nil })
+	p.Stage("none", 0, 1, func(v interface{}, emit Emit) error { return nil })
+}
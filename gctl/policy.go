@@ -0,0 +1,105 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import "github.com/npat-efault/gohacks/errors"
+
+// Action is the action taken when a goroutine exits with an error.
+// See KillPolicy.
+type Action int
+
+// Actions for goroutine errors
+const (
+	// PolicyKill: The error becomes the context's exit status
+	// (unless another error has already killed the context,
+	// replacing errors only recorded), and the context is
+	// killed. This is the default.
+	PolicyKill Action = iota
+	// PolicyRecord: The error becomes the context's exit status
+	// (if no other error has been recorded, and the context has
+	// not already failed), but the context is not killed.
+	PolicyRecord
+	// PolicyIgnore: The error is ignored.
+	PolicyIgnore
+)
+
+// KillPolicy is a function that decides the action to take when a
+// goroutine exits with the non-nil error err. It is not consulted for
+// ErrKilled, which always has the PolicyKill effect. A KillPolicy is
+// called with the context's internal lock held, therefore it must not
+// call any methods of the context. It may, though, log the error.
+type KillPolicy func(err error) Action
+
+// On returns a KillPolicy that takes action act for errors that
+// satisfy predicate pred (e.g. errors.IsTemporary), and PolicyKill
+// for all others.
+func On(pred func(error) bool, act Action) KillPolicy {
+	return func(err error) Action {
+		if pred(err) {
+			return act
+		}
+		return PolicyKill
+	}
+}
+
+// Chain returns a KillPolicy that consults policies ps in order and
+// takes the first action that is not PolicyKill. If all of them
+// return PolicyKill, so does the returned policy.
+func Chain(ps ...KillPolicy) KillPolicy {
+	return func(err error) Action {
+		for _, p := range ps {
+			if a := p(err); a != PolicyKill {
+				return a
+			}
+		}
+		return PolicyKill
+	}
+}
+
+// Ready-made kill policies.
+var (
+	// KillAlways kills the context for every error (the default).
+	KillAlways KillPolicy = func(error) Action { return PolicyKill }
+	// IgnoreTemporary ignores errors that test true with
+	// errors.IsTemporary.
+	IgnoreTemporary = On(errors.IsTemporary, PolicyIgnore)
+	// RecordTemporary records, without killing the context, errors
+	// that test true with errors.IsTemporary.
+	RecordTemporary = On(errors.IsTemporary, PolicyRecord)
+	// IgnoreClosed ignores errors that test true with
+	// errors.IsClosed.
+	IgnoreClosed = On(errors.IsClosed, PolicyIgnore)
+	// RecordTimeout records, without killing the context, errors
+	// that test true with errors.IsTimeout.
+	RecordTimeout = On(errors.IsTimeout, PolicyRecord)
+)
+
+// SetPolicy sets the kill policy of context c: the policy that
+// decides how the errors returned by its goroutines affect the
+// context. A nil p restores the default (kill on any error). It can
+// be called at any time, and affects goroutines that exit after the
+// call. See also Gcx.GoPolicy.
+func (c *Gcx) SetPolicy(p KillPolicy) {
+	c.mu.Lock()
+	c.kpol = p
+	c.mu.Unlock()
+}
+
+// GoPolicy is the same as Gcx.Go, but the exit status of the
+// goroutine is handled according to policy p, instead of the kill
+// policy of the context.
+func (c *Gcx) GoPolicy(p KillPolicy, f func() error) {
+	c.spawn(1, "", p, f)
+}
+
+// action returns the action to take for goroutine error err, given
+// kill policy pol. Must be called with c.mu held.
+func (c *Gcx) action(err error, pol KillPolicy) Action {
+	if err == ErrKilled || pol == nil {
+		return PolicyKill
+	}
+	return pol(err)
+}
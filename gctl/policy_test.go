@@ -0,0 +1,96 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestPolicy(t *testing.T) {
+	errTmp := errors.ErrNL(errors.ErrTemporary, "temporary")
+	errTmo := errors.ErrNL(errors.ErrTimeout, "timeout")
+	errCl := errors.ErrNL(errors.ErrClosed, "closed")
+	errPerm := errors.New("permanent")
+	pol := Chain(IgnoreTemporary, IgnoreClosed, RecordTimeout)
+
+	for _, tc := range []struct {
+		err    error
+		xs     error
+		killed bool
+	}{
+		{errTmp, nil, false},
+		{errCl, nil, false},
+		{errTmo, errTmo, false},
+		{errPerm, errPerm, true},
+	} {
+		var c Gcx
+		c.SetPolicy(pol)
+		c.Go(func() error {
+			select {
+			case <-c.ChKill():
+				return ErrKilled
+			case <-time.After(50 * time.Millisecond):
+				return nil
+			}
+		})
+		c.Go(func() error { return tc.err })
+		if e := c.Wait(); e != tc.xs {
+			t.Fatalf("%v: Wait: %v", tc.err, e)
+		}
+		select {
+		case <-c.kill:
+			if !tc.killed {
+				t.Fatalf("%v: context killed", tc.err)
+			}
+		default:
+			if tc.killed {
+				t.Fatalf("%v: context not killed", tc.err)
+			}
+		}
+	}
+}
+
+func TestGoPolicy(t *testing.T) {
+	errTmp := errors.ErrNL(errors.ErrTemporary, "temporary")
+	var c Gcx
+	c.Go(waitKill(&c))
+	c.GoPolicy(RecordTemporary, func() error { return errTmp })
+	if e := c.WaitTimeout(50 * time.Millisecond); e != ErrWaitTimeout {
+		t.Fatalf("WaitTimeout: %v", e)
+	}
+	c.Go(func() error { return errTmp })
+	if e := c.Wait(); e != errTmp {
+		t.Fatalf("Wait: %v", e)
+	}
+}
+
+// exitSched is a Scheduler that signals the channel each time the
+// exit status of a goroutine has been handled.
+type exitSched chan struct{}
+
+func (s exitSched) Spawn(name string) (begin, end func()) {
+	return nil, func() { s <- struct{}{} }
+}
+
+func TestPolicyOrder(t *testing.T) {
+	errTmp := errors.ErrNL(errors.ErrTemporary, "temporary")
+	errFail := errors.New("fail")
+	exited := make(exitSched, 3)
+	var c Gcx
+	c.SetScheduler(exited)
+	c.SetPolicy(RecordTemporary)
+	c.Go(waitKill(&c))
+	c.Go(func() error { return errTmp })
+	<-exited
+	// A killing error replaces the one recorded
+	c.Go(func() error { return errFail })
+	if e := c.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+}
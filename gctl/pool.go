@@ -0,0 +1,229 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+var (
+	// ErrPoolClosed is returned when submitting tasks to a pool
+	// that has been closed or killed. It tests true with
+	// errors.IsClosed().
+	ErrPoolClosed = errors.ErrNL(errors.ErrClosed, "Pool closed")
+	// ErrPoolFull is returned by Pool.TrySubmit if the task queue
+	// is full. It tests true with errors.IsTemporary().
+	ErrPoolFull = errors.ErrNL(errors.ErrTemporary, "Pool queue full")
+	// ErrNotRun is the result of tasks that were still queued
+	// when the pool was killed.
+	ErrNotRun = errors.New("Pool task not run")
+)
+
+// PoolPolicy determines how task errors affect a Pool.
+type PoolPolicy int
+
+// Pool failure policies
+const (
+	// PoolReport: Task errors are only reported as task results.
+	PoolReport PoolPolicy = iota
+	// PoolKill: A task error (other than ErrKilled) is reported as
+	// the task's result, and also kills the pool. The error
+	// becomes the pool's exit status.
+	PoolKill
+)
+
+// Job is a task submitted to a Pool.
+type Job struct {
+	f    func() (interface{}, error)
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+func (j *Job) finish(v interface{}, err error) {
+	j.val, j.err = v, err
+	close(j.done)
+}
+
+// Done returns a channel that is closed when the job has completed
+// (or has been discarded, if the pool was killed before running it).
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Result waits for job j to complete and returns the value and error
+// returned by the task function. If the pool was killed before the
+// job was run, Result returns nil, ErrNotRun.
+func (j *Job) Result() (interface{}, error) {
+	<-j.done
+	return j.val, j.err
+}
+
+// Pool is a fixed-size pool of worker goroutines, running in the
+// pool's embedded Gcx, that execute tasks taken from a bounded queue.
+//
+// Killing the pool (Gcx.Kill) stops the workers as soon as they
+// finish the tasks they are running (tasks can monitor Gcx.ChKill to
+// terminate early); tasks still in the queue are discarded and their
+// result is ErrNotRun. Closing the pool (Pool.Close) stops it from
+// accepting new tasks; the workers run the tasks already in the
+// queue, and then exit.
+type Pool struct {
+	Gcx
+	policy   PoolPolicy
+	mu       sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	queue    []*Job
+	size     int
+	closed   bool // not accepting tasks
+	stopped  bool // killed, workers must exit
+	quit     chan struct{}
+}
+
+// NewPool creates and starts a pool with the given number of
+// workers, and a task queue that can hold up to qsize tasks. Policy
+// pol determines how task errors affect the pool. Tasks are always
+// passed to the workers through the queue, therefore qsize must be at
+// least 1. If workers or qsize are less than 1, NewPool panics.
+func NewPool(workers, qsize int, pol PoolPolicy) *Pool {
+	if workers < 1 {
+		panic("gctl.NewPool: number of workers must be at least 1")
+	}
+	if qsize < 1 {
+		panic("gctl.NewPool: queue size must be at least 1")
+	}
+	p := &Pool{policy: pol, size: qsize}
+	p.notEmpty.L = &p.mu
+	p.notFull.L = &p.mu
+	p.quit = make(chan struct{})
+	for i := 0; i < workers; i++ {
+		p.GoNamed("pool worker", p.work)
+	}
+	p.GoNamed("pool monitor", p.monitor)
+	return p
+}
+
+// Submit queues task f for execution, waiting while the queue is
+// full. It returns ErrPoolClosed if the pool is (or, while waiting,
+// becomes) closed or killed.
+func (p *Pool) Submit(f func() (interface{}, error)) (*Job, error) {
+	return p.submit(f, true)
+}
+
+// TrySubmit is like Pool.Submit, but does not wait. If the queue is
+// full it returns ErrPoolFull.
+func (p *Pool) TrySubmit(f func() (interface{}, error)) (*Job, error) {
+	return p.submit(f, false)
+}
+
+func (p *Pool) submit(f func() (interface{}, error), wait bool) (*Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.closed && len(p.queue) >= p.size {
+		if !wait {
+			return nil, ErrPoolFull
+		}
+		p.notFull.Wait()
+	}
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	j := &Job{f: f, done: make(chan struct{})}
+	p.queue = append(p.queue, j)
+	p.notEmpty.Signal()
+	return j, nil
+}
+
+// Close stops pool p from accepting new tasks. Tasks already queued
+// are run, and then the workers exit. Close returns ErrPoolClosed if
+// the pool is already closed or killed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.closed = true
+	close(p.quit)
+	p.notEmpty.Broadcast()
+	p.notFull.Broadcast()
+	return nil
+}
+
+// Queued returns the number of tasks waiting in the queue.
+func (p *Pool) Queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// monitor waits for the pool to be killed or closed. If killed it
+// stops the pool.
+func (p *Pool) monitor() error {
+	select {
+	case <-p.ChKill():
+		p.stop()
+		return ErrKilled
+	case <-p.quit:
+		return nil
+	}
+}
+
+// stop stops the workers and discards the queued tasks.
+func (p *Pool) stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	if !p.closed {
+		p.closed = true
+		close(p.quit)
+	}
+	p.stopped = true
+	q := p.queue
+	p.queue = nil
+	p.notEmpty.Broadcast()
+	p.notFull.Broadcast()
+	p.mu.Unlock()
+	for _, j := range q {
+		j.finish(nil, ErrNotRun)
+	}
+}
+
+func (p *Pool) work() error {
+	kill := p.ChKill()
+	for {
+		select {
+		case <-kill:
+			p.stop()
+			return nil
+		default:
+		}
+		p.mu.Lock()
+		for !p.stopped && !p.closed && len(p.queue) == 0 {
+			p.notEmpty.Wait()
+		}
+		if p.stopped || len(p.queue) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		j := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.notFull.Signal()
+		p.mu.Unlock()
+
+		v, err := j.f()
+		j.finish(v, err)
+		if err != nil && err != ErrKilled && p.policy == PoolKill {
+			p.killWith(err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestPoolClose(t *testing.T) {
+	p := NewPool(3, 4, PoolReport)
+	errOdd := errors.New("odd")
+	var js []*Job
+	for i := 0; i < 20; i++ {
+		i := i
+		j, err := p.Submit(func() (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			if i%2 != 0 {
+				return nil, errOdd
+			}
+			return i, nil
+		})
+		if err != nil {
+			t.Fatalf("Submit %d: %v", i, err)
+		}
+		js = append(js, j)
+	}
+	p.Close()
+	if _, err := p.Submit(nil); err != ErrPoolClosed {
+		t.Fatalf("Submit after Close: %v", err)
+	}
+	if e := p.Wait(); e != nil {
+		t.Fatalf("Wait: %v", e)
+	}
+	for i, j := range js {
+		v, err := j.Result()
+		if i%2 != 0 && err != errOdd || i%2 == 0 && v != i {
+			t.Fatalf("Job %d: %v, %v", i, v, err)
+		}
+	}
+}
+
+func TestPoolKill(t *testing.T) {
+	p := NewPool(1, 2, PoolKill)
+	block := make(chan struct{})
+	errFail := errors.New("fail")
+	j0, _ := p.Submit(func() (interface{}, error) {
+		<-block
+		return nil, errFail
+	})
+	for p.Queued() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	j1, _ := p.Submit(func() (interface{}, error) { return 1, nil })
+	j2, _ := p.TrySubmit(func() (interface{}, error) { return 2, nil })
+	if _, err := p.TrySubmit(nil); err != ErrPoolFull {
+		t.Fatalf("TrySubmit: %v", err)
+	}
+	close(block)
+	if e := p.Wait(); e != errFail {
+		t.Fatalf("Wait: %v", e)
+	}
+	if _, err := j0.Result(); err != errFail {
+		t.Fatalf("j0: %v", err)
+	}
+	for _, j := range []*Job{j1, j2} {
+		if _, err := j.Result(); err != ErrNotRun {
+			t.Fatalf("Job: %v", err)
+		}
+	}
+}
+
+func TestPoolArgs(t *testing.T) {
+	for _, a := range []struct{ workers, qsize int }{{1, 0}, {0, 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("NewPool%v: No panic", a)
+				}
+			}()
+			NewPool(a.workers, a.qsize, PoolReport)
+		}()
+	}
+}
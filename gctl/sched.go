@@ -0,0 +1,173 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// Schedule determines when a scheduled job runs. See Gcx.Schedule.
+type Schedule interface {
+	// Next returns the time of the first run after t. A zero
+	// time means there are no more runs.
+	Next(t time.Time) time.Time
+}
+
+// Interval is a Schedule with runs separated by a fixed interval.
+type Interval time.Duration
+
+// Next returns t plus the interval.
+func (d Interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// Overlap determines what happens when a scheduled job is due to run
+// while its previous run has not yet finished.
+type Overlap int
+
+// Overlap modes
+const (
+	// OverlapSkip: The run is skipped.
+	OverlapSkip Overlap = iota
+	// OverlapQueue: The run is delayed until the previous one
+	// finishes. Runs are never concurrent.
+	OverlapQueue
+	// OverlapAllow: The run starts immediately, concurrently with
+	// the previous one.
+	OverlapAllow
+)
+
+// JobOpts are options for scheduled jobs. See Gcx.Schedule.
+type JobOpts struct {
+	// Name of the job's goroutines (see Gcx.GoNamed).
+	Name string
+	// Overlap mode. The default is OverlapSkip.
+	Overlap Overlap
+	// If not zero, each run is delayed by a random duration in
+	// [0, Jitter).
+	Jitter time.Duration
+}
+
+// Schedule runs function f, in context c, at the times determined by
+// schedule s. Each run of f is a separate goroutine of c and its exit
+// status is handled like that of any other goroutine: By default, an
+// error kills the context, but this can be changed with a kill policy
+// (see Gcx.SetPolicy). Options o control the overlap of runs and the
+// jitter of the run times.
+//
+// The schedule is driven by an additional goroutine in c, which
+// exits when c is killed (or when s has no more runs). Therefore, as
+// long as a schedule is active, c does not terminate unless killed.
+// If c is dead, Schedule panics, like Gcx.Go.
+func (c *Gcx) Schedule(s Schedule, o JobOpts, f func() error) {
+	loc := caller(0)
+	c.schedule(loc, s, o, f)
+}
+
+// Every is the same as calling Gcx.Schedule with Interval(d) and the
+// default options.
+func (c *Gcx) Every(d time.Duration, f func() error) {
+	loc := caller(0)
+	c.schedule(loc, Interval(d), JobOpts{}, f)
+}
+
+// After runs function f in context c, once, after delay d. If c is
+// killed before d elapses, f is not run. The goroutine waiting for
+// the delay, and then running f, is a goroutine of c, so c does not
+// terminate before f runs (or c is killed).
+func (c *Gcx) After(d time.Duration, f func() error) {
+	loc := caller(0)
+	c.spawnAt(loc, "after", nil, func() error {
+		tm := time.NewTimer(d)
+		defer tm.Stop()
+		select {
+		case <-c.ChKill():
+			return ErrKilled
+		case <-tm.C:
+		}
+		return f()
+	})
+}
+
+func (c *Gcx) schedule(loc errors.Location, s Schedule, o JobOpts,
+	f func() error) {
+	name := o.Name
+	if name == "" {
+		name = "scheduled"
+	}
+	c.spawnAt(loc, name+" scheduler", nil, func() error {
+		kill := c.ChKill()
+		done := make(chan struct{})
+		run := func() {
+			c.spawnAt(loc, name, nil, func() error {
+				err := f()
+				select {
+				case done <- struct{}{}:
+				case <-kill:
+				}
+				return err
+			})
+		}
+
+		running, pending := 0, 0
+		finished := func() {
+			running--
+			if pending > 0 {
+				pending--
+				running++
+				run()
+			}
+		}
+
+		var tm *time.Timer
+		defer func() {
+			if tm != nil {
+				tm.Stop()
+			}
+		}()
+		t := time.Now()
+		for {
+			t = s.Next(t)
+			if t.IsZero() {
+				break
+			}
+			at := t
+			if o.Jitter > 0 {
+				at = at.Add(time.Duration(rand.Int63n(int64(o.Jitter))))
+			}
+			tm = time.NewTimer(at.Sub(time.Now()))
+			for fired := false; !fired; {
+				select {
+				case <-kill:
+					return ErrKilled
+				case <-done:
+					finished()
+				case <-tm.C:
+					fired = true
+				}
+			}
+			if running == 0 || o.Overlap == OverlapAllow {
+				running++
+				run()
+			} else if o.Overlap == OverlapQueue {
+				pending++
+			}
+		}
+		// No more runs. Wait for the running and queued ones.
+		for running > 0 {
+			select {
+			case <-kill:
+				return ErrKilled
+			case <-done:
+				finished()
+			}
+		}
+		return nil
+	})
+}
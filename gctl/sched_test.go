@@ -0,0 +1,158 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	base := time.Date(2015, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2015, 3, 14, 15, 10, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2015, 3, 14, 15, 15, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2015, 3, 14, 15, 20, 0, 0, time.UTC)},
+		{"5-59/15 * * * *", time.Date(2015, 3, 14, 15, 20, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2015, 3, 14, 17, 0, 0, 0, time.UTC)},
+		{"30 2 1,15 * *", time.Date(2015, 3, 15, 2, 30, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2015, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2015, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 1", time.Date(2015, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */2 * 1", time.Date(2015, 3, 23, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		if n := s.Next(base); !n.Equal(tc.next) {
+			t.Fatalf("%q: Next: %v != %v", tc.expr, n, tc.next)
+		}
+	}
+	for _, expr := range []string{
+		"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *",
+		"*/0 * * * *", "x * * * *", "@every -1s",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("%q: no error", expr)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	var c Gcx
+	var n int32
+	c.Every(5*time.Millisecond, func() error {
+		atomic.AddInt32(&n, 1)
+		return nil
+	})
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&n) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job run %d times", atomic.LoadInt32(&n))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if e := c.KillWait(); e != ErrKilled {
+		t.Fatalf("KillWait: %v", e)
+	}
+}
+
+// testTicks is a Schedule with a run due each time a value is sent on
+// the channel. Closing the channel ends the schedule. Since the
+// channel is unbuffered, a send completes only after the scheduler
+// has handled the previous run.
+type testTicks chan struct{}
+
+func (tk testTicks) Next(time.Time) time.Time {
+	if _, ok := <-tk; !ok {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func TestScheduleOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		ov      Overlap
+		maxConc int
+		runs    int
+	}{
+		{OverlapSkip, 1, 1},
+		{OverlapQueue, 1, 3},
+		{OverlapAllow, 3, 3},
+	} {
+		var c Gcx
+		var mu sync.Mutex
+		var conc, maxConc, runs int
+		tk := make(testTicks)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		c.Schedule(tk, JobOpts{Overlap: tc.ov}, func() error {
+			mu.Lock()
+			conc++
+			runs++
+			if conc > maxConc {
+				maxConc = conc
+			}
+			mu.Unlock()
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			conc--
+			mu.Unlock()
+			return nil
+		})
+		// Three runs due; the first one is still running when
+		// the others become due.
+		tk <- struct{}{}
+		<-started
+		tk <- struct{}{}
+		if tc.ov == OverlapAllow {
+			<-started
+		}
+		tk <- struct{}{}
+		if tc.ov == OverlapAllow {
+			<-started
+		}
+		close(tk)
+		for i := 0; i < tc.runs; i++ {
+			if i > 0 && tc.ov != OverlapAllow {
+				<-started
+			}
+			release <- struct{}{}
+		}
+		if e := c.Wait(); e != nil {
+			t.Fatalf("overlap %d: Wait: %v", tc.ov, e)
+		}
+		if maxConc != tc.maxConc || runs != tc.runs {
+			t.Fatalf("overlap %d: %d runs, %d max concurrent",
+				tc.ov, runs, maxConc)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	var c Gcx
+	ran := false
+	c.After(10*time.Millisecond, func() error { ran = true; return nil })
+	if e := c.Wait(); e != nil || !ran {
+		t.Fatalf("Wait: %v, ran: %v", e, ran)
+	}
+
+	var c1 Gcx
+	c1.After(time.Second, func() error { ran = false; return nil })
+	if e := c1.KillWait(); e != ErrKilled || !ran {
+		t.Fatalf("KillWait: %v, ran: %v", e, ran)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+// Scheduler controls when the goroutines of a context run. It is
+// meant for testing code that uses contexts under reproducible
+// goroutine interleavings; package gcxtest provides an
+// implementation (gcxtest.Sched). See Gcx.SetScheduler.
+type Scheduler interface {
+	// Spawn is called by Gcx.Go (and the related methods), in the
+	// calling goroutine, for each new goroutine of the context,
+	// with the goroutine's name. It is called with the context's
+	// internal lock held, therefore it must not call any methods
+	// of the context. The new goroutine calls begin before
+	// running its function, and end once it has returned and its
+	// exit status has been handled by the context.
+	Spawn(name string) (begin, end func())
+}
+
+// SetScheduler sets the scheduler for the goroutines of context c.
+// Like Gcx.SetGroup, it must be called before the context is
+// started, otherwise it panics. If no scheduler is set for a context,
+// the scheduler of its parent is used, if any.
+func (c *Gcx) SetScheduler(s Scheduler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill != nil {
+		panic("Gcx.SetScheduler: Gcx context not empty")
+	}
+	c.sched = s
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the value with which RunScope re-raises a panic of a
+// goroutine started in the scope.
+type PanicError struct {
+	Value interface{} // The value passed to panic
+	Stack []byte      // Stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("Gcx scope goroutine panicked: %v\n%s",
+		e.Value, e.Stack)
+}
+
+// Scope is the goroutine context of a structured-concurrency scope.
+// See RunScope.
+type Scope struct {
+	Gcx
+	mu sync.Mutex
+	pe *PanicError // first panic
+}
+
+// RunScope calls function f, in the calling goroutine, passing it a
+// new Scope. Function f (and the goroutines it starts) can start
+// goroutines in the scope using Scope.Go and Scope.GoNamed. RunScope
+// does not return before all goroutines in the scope (and all child
+// contexts of the scope) have terminated, so none of them can
+// outlive the call. It returns the exit status of the scope's
+// context, determined by the Gcx rules: An error returned by f, or by
+// one of the goroutines, kills the scope and becomes its exit status
+// (goroutine errors are subject to the scope's kill policy, see
+// Gcx.SetPolicy).
+//
+// If f, or one of the goroutines started with Scope.Go or
+// Scope.GoNamed, panics, the scope is killed, all its goroutines are
+// waited for, and then RunScope panics, in the calling goroutine,
+// with a *PanicError describing the first panic.
+func RunScope(f func(s *Scope) error) error {
+	s := &Scope{}
+	s.hold()
+	func() {
+		defer func() {
+			if x := recover(); x != nil {
+				s.killWith(s.panicked(x))
+			}
+		}()
+		if err := f(s); err != nil {
+			s.killWith(err)
+		}
+	}()
+	s.exit(nil, nil, nil)
+	err := s.Gcx.Wait()
+	s.mu.Lock()
+	pe := s.pe
+	s.mu.Unlock()
+	if pe != nil {
+		panic(pe)
+	}
+	return err
+}
+
+// panicked records the panic with value x, if it is the first one in
+// scope s, and returns its description.
+func (s *Scope) panicked(x interface{}) *PanicError {
+	pe := &PanicError{Value: x, Stack: debug.Stack()}
+	s.mu.Lock()
+	if s.pe == nil {
+		s.pe = pe
+	}
+	s.mu.Unlock()
+	return pe
+}
+
+// guard wraps f so that a panic in it is recovered, recorded, and
+// kills the scope.
+func (s *Scope) guard(f func() error) func() error {
+	return func() (err error) {
+		defer func() {
+			if x := recover(); x != nil {
+				pe := s.panicked(x)
+				s.killWith(pe)
+				err = pe
+			}
+		}()
+		return f()
+	}
+}
+
+// Go runs function f as a goroutine in the scope, like Gcx.Go. A
+// panic in f is recovered and re-raised by RunScope. It must only be
+// called while RunScope is running.
+func (s *Scope) Go(f func() error) {
+	s.spawn(1, "", nil, s.guard(f))
+}
+
+// GoNamed is the same as Scope.Go, but also assigns a name to the
+// goroutine (see Gcx.GoNamed).
+func (s *Scope) GoNamed(name string, f func() error) {
+	s.spawn(1, name, nil, s.guard(f))
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func TestScopeJoin(t *testing.T) {
+	var n int32
+	e := RunScope(func(s *Scope) error {
+		for i := 0; i < 5; i++ {
+			s.Go(func() error {
+				s.Go(func() error {
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&n, 1)
+					return nil
+				})
+				return nil
+			})
+		}
+		return nil
+	})
+	if e != nil {
+		t.Fatalf("RunScope: %v", e)
+	}
+	if n != 5 {
+		t.Fatalf("RunScope returned early: %d", n)
+	}
+}
+
+func TestScopeError(t *testing.T) {
+	errFail := errors.New("fail")
+	var killed int32
+	e := RunScope(func(s *Scope) error {
+		s.Go(func() error {
+			<-s.ChKill()
+			atomic.StoreInt32(&killed, 1)
+			return ErrKilled
+		})
+		s.Go(func() error { return errFail })
+		return nil
+	})
+	if e != errFail || killed != 1 {
+		t.Fatalf("RunScope: %v, killed %d", e, killed)
+	}
+
+	e = RunScope(func(s *Scope) error {
+		s.Go(waitKill(&s.Gcx))
+		return errFail
+	})
+	if e != errFail {
+		t.Fatalf("RunScope: %v", e)
+	}
+}
+
+func TestScopePanic(t *testing.T) {
+	var joined int32
+	func() {
+		defer func() {
+			pe, ok := recover().(*PanicError)
+			if !ok || pe.Value != "boom" || len(pe.Stack) == 0 {
+				t.Fatalf("Bad panic: %v", pe)
+			}
+			if atomic.LoadInt32(&joined) != 1 {
+				t.Fatal("Panic re-raised before siblings joined")
+			}
+		}()
+		RunScope(func(s *Scope) error {
+			s.Go(func() error {
+				<-s.ChKill()
+				time.Sleep(10 * time.Millisecond)
+				atomic.StoreInt32(&joined, 1)
+				return ErrKilled
+			})
+			s.Go(func() error { panic("boom") })
+			return nil
+		})
+		t.Fatal("RunScope did not panic")
+	}()
+
+	func() {
+		defer func() {
+			if pe, ok := recover().(*PanicError); !ok || pe.Value != "caller" {
+				t.Fatalf("Bad panic: %v", pe)
+			}
+		}()
+		RunScope(func(s *Scope) error {
+			s.Go(waitKill(&s.Gcx))
+			panic("caller")
+		})
+	}()
+}
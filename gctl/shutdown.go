@@ -0,0 +1,133 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+// ErrGraceExpired is the kill reason (see Gcx.Reason) of a context
+// killed by Gcx.Shutdown because it did not terminate within the
+// grace period. It tests true with errors.IsTimeout().
+var ErrGraceExpired = errors.ErrNL(errors.ErrTimeout,
+	"Gcx shutdown grace period expired")
+
+// Phase is the shutdown phase of a context. See Gcx.Phase.
+type Phase int
+
+// Shutdown phases
+const (
+	// PhaseRunning: Neither drain nor kill has been requested.
+	PhaseRunning Phase = iota
+	// PhaseDraining: Drain has been requested (see Gcx.Drain), but
+	// kill has not.
+	PhaseDraining
+	// PhaseKilled: Kill has been requested (see Gcx.Kill).
+	PhaseKilled
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseRunning:
+		return "running"
+	case PhaseDraining:
+		return "draining"
+	case PhaseKilled:
+		return "killed"
+	default:
+		return "unknown"
+	}
+}
+
+// ChDrain is intended to be called from the goroutines of context c,
+// and returns the channel upon which the goroutines should wait for a
+// drain request: A request to stop accepting new work, finish the
+// work in progress, and then exit. Drain is requested by closing the
+// channel. Since killing a context implies draining it, the channel
+// is also closed when the context is killed, so goroutines that only
+// care about orderly shutdown need to monitor only this channel.
+func (c *Gcx) ChDrain() <-chan struct{} {
+	c.mu.Lock()
+	if c.kill == nil {
+		c.mu.Unlock()
+		panic("Gcx.ChDrain: Gcx is not a running context")
+	}
+	c.mu.Unlock()
+	return c.drain
+}
+
+// startDrain closes the drain channel of context c, if not already
+// closed. Must be called with c.mu held.
+func (c *Gcx) startDrain() {
+	if c.draining {
+		return
+	}
+	c.draining = true
+	if c.ngort != -1 && c.phase < PhaseDraining {
+		c.phase = PhaseDraining
+	}
+	close(c.drain)
+}
+
+// Drain signals goroutines in context c to stop accepting new work,
+// and exit once the work in progress is done, by closing the channel
+// returned by Gcx.ChDrain. Child contexts of c are drained as well.
+// Unlike Gcx.Kill, Drain does not close the channel returned by
+// Gcx.ChKill. If the context is empty, it returns ErrGcxEmpty. It is
+// ok to call Drain multiple times, or concurrently from multiple
+// goroutines.
+func (c *Gcx) Drain() error {
+	c.mu.Lock()
+	if c.kill == nil {
+		c.mu.Unlock()
+		return ErrGcxEmpty
+	}
+	if c.draining {
+		c.mu.Unlock()
+		return nil
+	}
+	c.startDrain()
+	kids := make([]*Gcx, 0, len(c.kids))
+	for k := range c.kids {
+		kids = append(kids, k)
+	}
+	c.mu.Unlock()
+	for _, k := range kids {
+		k.Drain()
+	}
+	return nil
+}
+
+// Shutdown shuts down context c in two phases: It first drains c (see
+// Gcx.Drain) and waits for up to grace for it to terminate. If c does
+// not terminate within the grace period, Shutdown kills it, with
+// reason ErrGraceExpired (see Gcx.KillWithReason), and waits for it
+// to terminate. It returns the exit status of c. Gcx.Phase can be
+// used, afterwards, to tell which phase ended the context. If the
+// context is empty, it returns ErrGcxEmpty.
+func (c *Gcx) Shutdown(grace time.Duration) error {
+	if err := c.Drain(); err != nil {
+		return err
+	}
+	if err := c.WaitTimeout(grace); err != ErrWaitTimeout {
+		return err
+	}
+	c.KillWithReason(ErrGraceExpired)
+	return c.Wait()
+}
+
+// Phase returns the shutdown phase of context c. Once the context
+// has terminated, the phase no longer changes, and indicates the
+// phase that ended the context: PhaseRunning if it terminated on its
+// own, PhaseDraining if it terminated after a drain request, and
+// PhaseKilled if it terminated after being killed.
+func (c *Gcx) Phase() Phase {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.phase
+}
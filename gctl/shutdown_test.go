@@ -0,0 +1,87 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	var c Gcx
+	if e := c.Shutdown(time.Second); e != ErrGcxEmpty {
+		t.Fatalf("Shutdown: %v", e)
+	}
+	var k Gcx
+	c.Go(func() error {
+		<-c.ChDrain()
+		time.Sleep(10 * time.Millisecond) // finish in-flight work
+		return nil
+	})
+	k.SetParent(&c, ChildKill)
+	k.Go(func() error {
+		<-k.ChDrain()
+		return nil
+	})
+	if p := c.Phase(); p != PhaseRunning {
+		t.Fatalf("Phase: %v", p)
+	}
+	if e := c.Shutdown(time.Second); e != nil {
+		t.Fatalf("Shutdown: %v", e)
+	}
+	if p := c.Phase(); p != PhaseDraining {
+		t.Fatalf("Phase: %v", p)
+	}
+	if r := c.Reason(); r != nil {
+		t.Fatalf("Reason: %v", r)
+	}
+	select {
+	case <-c.ChKill():
+		t.Fatal("Kill channel closed")
+	default:
+	}
+	// Phase is frozen, once dead.
+	c.Kill()
+	if p := c.Phase(); p != PhaseDraining {
+		t.Fatalf("Phase after death: %v", p)
+	}
+}
+
+func TestShutdownKill(t *testing.T) {
+	var c Gcx
+	c.Go(func() error {
+		// Ignore drain
+		<-c.ChKill()
+		return ErrKilled
+	})
+	start := time.Now()
+	if e := c.Shutdown(20 * time.Millisecond); e != ErrKilled {
+		t.Fatalf("Shutdown: %v", e)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Fatalf("Shutdown too early: %v", d)
+	}
+	if p := c.Phase(); p != PhaseKilled {
+		t.Fatalf("Phase: %v", p)
+	}
+	if r := c.Reason(); r != ErrGraceExpired {
+		t.Fatalf("Reason: %v", r)
+	}
+}
+
+func TestKillDrains(t *testing.T) {
+	var c Gcx
+	c.Go(func() error {
+		<-c.ChDrain()
+		return nil
+	})
+	if e := c.KillWait(); e != nil {
+		t.Fatalf("KillWait: %v", e)
+	}
+	if p := c.Phase(); p != PhaseKilled {
+		t.Fatalf("Phase: %v", p)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SignalError is the exit status of a context killed by an OS signal
+// (see SignalHandler).
+type SignalError struct {
+	Sig os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "Gcx context killed by signal: " + e.Sig.String()
+}
+
+// osExit is called by the default hard action. Tests replace it, so
+// that the hard action can be checked without exiting.
+var osExit = os.Exit
+
+// SignalHandler binds OS signals to a goroutine context, for
+// graceful shutdown. See SignalHandler.Bind.
+type SignalHandler struct {
+	// Signals that kill the context. If empty, os.Interrupt and
+	// syscall.SIGTERM are used.
+	Signals []os.Signal
+	// Grace is the time allowed for the context to terminate
+	// after the first signal is received. When it expires the
+	// hard action is performed. Zero means no time limit.
+	Grace time.Duration
+	// Hard, if not nil, is called, after the cleanup functions,
+	// as the last step of the hard action. If nil, the hard
+	// action exits the process with status ExitCode.
+	Hard func()
+	// ExitCode is the process exit status used by the default
+	// hard action. Zero means 1.
+	ExitCode int
+	// Reload, if not nil, is called every time syscall.SIGHUP is
+	// received.
+	Reload func()
+
+	mu       sync.Mutex
+	cleanups []func()
+}
+
+// Cleanup registers function f to be called as part of the hard
+// action. Cleanup functions are called in the reverse order of their
+// registration.
+func (h *SignalHandler) Cleanup(f func()) {
+	h.mu.Lock()
+	h.cleanups = append(h.cleanups, f)
+	h.mu.Unlock()
+}
+
+// Bind starts monitoring the signals configured in h, on behalf of
+// the running context c. The first such signal kills c with exit
+// status *SignalError (unless c has already failed). A second signal,
+// or the expiration of the grace period, while c has not yet
+// terminated, triggers the hard action: The registered cleanup
+// functions are called, and then either h.Hard is called, or the
+// process exits. Monitoring stops when c terminates. If c is empty,
+// Bind returns ErrGcxEmpty. The fields of h must not be modified
+// after calling Bind.
+func (h *SignalHandler) Bind(c *Gcx) error {
+	c.mu.Lock()
+	if c.kill == nil {
+		c.mu.Unlock()
+		return ErrGcxEmpty
+	}
+	dead := c.dead
+	c.mu.Unlock()
+
+	sigs := h.Signals
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, sigs...)
+	if h.Reload != nil {
+		signal.Notify(ch, syscall.SIGHUP)
+	}
+	go h.run(c, ch, dead)
+	return nil
+}
+
+func (h *SignalHandler) run(c *Gcx, ch chan os.Signal, dead <-chan struct{}) {
+	defer signal.Stop(ch)
+	var grace <-chan time.Time
+	killed := false
+	for {
+		select {
+		case <-dead:
+			return
+		case sig := <-ch:
+			if sig == syscall.SIGHUP && h.Reload != nil {
+				h.Reload()
+				continue
+			}
+			if !killed {
+				killed = true
+				c.killWith(&SignalError{Sig: sig})
+				if h.Grace > 0 {
+					tm := time.NewTimer(h.Grace)
+					defer tm.Stop()
+					grace = tm.C
+				}
+				continue
+			}
+		case <-grace:
+		}
+		h.hard()
+		return
+	}
+}
+
+func (h *SignalHandler) hard() {
+	h.mu.Lock()
+	cs := h.cleanups
+	h.mu.Unlock()
+	for i := len(cs) - 1; i >= 0; i-- {
+		cs[i]()
+	}
+	if h.Hard != nil {
+		h.Hard()
+		return
+	}
+	code := h.ExitCode
+	if code == 0 {
+		code = 1
+	}
+	osExit(code)
+}
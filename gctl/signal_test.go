@@ -0,0 +1,86 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package gctl
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalHandler(t *testing.T) {
+	reload := make(chan struct{}, 1)
+	hard := make(chan struct{})
+	var order []int
+	h := &SignalHandler{
+		Signals: []os.Signal{syscall.SIGUSR1},
+		Grace:   50 * time.Millisecond,
+		Reload:  func() { reload <- struct{}{} },
+		Hard:    func() { close(hard) },
+	}
+	h.Cleanup(func() { order = append(order, 1) })
+	h.Cleanup(func() { order = append(order, 2) })
+
+	var c Gcx
+	if e := h.Bind(&c); e != ErrGcxEmpty {
+		t.Fatalf("Bind: %v", e)
+	}
+	stuck := make(chan struct{})
+	c.Go(func() error {
+		<-c.ChKill()
+		<-stuck // Ignore kill, until released
+		return ErrKilled
+	})
+	h.Bind(&c)
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	select {
+	case <-reload:
+	case <-time.After(time.Second):
+		t.Fatal("No reload")
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	select {
+	case <-c.ChKill():
+	case <-time.After(time.Second):
+		t.Fatal("Context not killed")
+	}
+	select {
+	case <-hard:
+	case <-time.After(time.Second):
+		t.Fatal("No hard action")
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Fatalf("Bad cleanup order: %v", order)
+	}
+	close(stuck)
+	e := c.Wait()
+	if se, ok := e.(*SignalError); !ok || se.Sig != syscall.SIGUSR1 {
+		t.Fatalf("Wait: %v", e)
+	}
+}
+
+func TestSignalHardExit(t *testing.T) {
+	// Cleanups are recorded as -1, exits with their code.
+	var calls []int
+	defer func(f func(int)) { osExit = f }(osExit)
+	osExit = func(code int) { calls = append(calls, code) }
+
+	h := &SignalHandler{}
+	h.Cleanup(func() { calls = append(calls, -1) })
+	h.hard()
+	h.ExitCode = 3
+	h.hard()
+	if len(calls) != 4 || calls[0] != -1 || calls[1] != 1 ||
+		calls[2] != -1 || calls[3] != 3 {
+		t.Fatalf("Hard action calls: %v", calls)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"fmt"
+	"time"
+)
+
+// Restart is the restart policy of a supervised child. See
+// ChildSpec.
+type Restart int
+
+// Restart policies
+const (
+	// Permanent children are always restarted.
+	Permanent Restart = iota
+	// Transient children are restarted only if they fail (exit
+	// with a non-nil, non-ErrKilled status).
+	Transient
+	// Temporary children are never restarted.
+	Temporary
+)
+
+// Strategy determines which children a Supervisor restarts when one
+// of them terminates and must be restarted.
+type Strategy int
+
+// Restart strategies
+const (
+	// OneForOne: Only the terminated child is restarted.
+	OneForOne Strategy = iota
+	// OneForAll: All other children are killed, and then all
+	// children are restarted.
+	OneForAll
+	// RestForOne: The children started after the terminated one
+	// (in the order of Supervisor.Children) are killed, and then
+	// the terminated child and the killed ones are restarted.
+	RestForOne
+)
+
+// Default restart intensity limits. See Supervisor.
+const (
+	DefaultIntensity = 3
+	DefaultPeriod    = 5 * time.Second
+)
+
+// ChildSpec specifies a child of a Supervisor.
+type ChildSpec struct {
+	// Name identifies the child in errors and introspection.
+	Name string
+	// Start is run, as a named goroutine, in a new context every
+	// time the child is (re)started. The context is passed as
+	// argument and can be used to monitor kill requests, or to
+	// start additional goroutines. The child terminates when its
+	// context terminates, and the context's exit status is the
+	// child's exit status.
+	Start func(c *Gcx) error
+	// Restart is the child's restart policy.
+	Restart Restart
+}
+
+// ChildFailure records the termination of a supervised child that
+// caused a restart.
+type ChildFailure struct {
+	Name string    // Child name
+	Time time.Time // Time of termination
+	Err  error     // Child exit status
+}
+
+// SupervisorError is the exit status of a Supervisor that gave up
+// because its restart intensity limit was exceeded. It lists the
+// child terminations within the last restart period.
+type SupervisorError struct {
+	Failures []ChildFailure
+}
+
+func (e *SupervisorError) Error() string {
+	s := "Supervisor restart limit exceeded"
+	for _, f := range e.Failures {
+		if f.Err != nil {
+			s += fmt.Sprintf("; %s: %v", f.Name, f.Err)
+		} else {
+			s += fmt.Sprintf("; %s: exited", f.Name)
+		}
+	}
+	return s
+}
+
+// Supervisor runs and monitors a number of children (see ChildSpec)
+// and restarts them, according to their restart policies and the
+// supervisor's restart strategy, when they terminate.
+//
+// Each child runs in its own context, which is a child context (see
+// Gcx.SetParent) of the supervisor's embedded Gcx. Killing the
+// supervisor kills all children, and waiting for the supervisor
+// waits for all children as well.
+//
+// If more than Intensity restarts occur within Period, the
+// supervisor gives up: It kills all children, waits for them to
+// terminate, and then terminates with a *SupervisorError exit
+// status. If both Intensity and Period are zero, DefaultIntensity and
+// DefaultPeriod are used. Before each restart the supervisor sleeps
+// for Backoff(n), where n is the number of restarts within the last
+// Period (including this one); if Backoff is nil, restarts are
+// immediate.
+//
+// The supervisor keeps running, even if it has no children left to
+// run, until it is killed, or until it gives up. The configuration
+// fields must not be modified after the supervisor is started.
+type Supervisor struct {
+	Gcx
+	Strategy  Strategy
+	Intensity int
+	Period    time.Duration
+	Backoff   func(n int) time.Duration
+	Children  []ChildSpec
+
+	grp      Group
+	running  []*Gcx // running child contexts, nil if not running
+	restarts []ChildFailure
+}
+
+// Start starts the supervisor and all its children, in order.
+func (s *Supervisor) Start() {
+	s.GoNamed("supervisor", s.run)
+}
+
+// ExpBackoff returns a backoff function (for Supervisor.Backoff)
+// that doubles the delay with each restart, starting from min, and
+// never exceeding max.
+func ExpBackoff(min, max time.Duration) func(n int) time.Duration {
+	return func(n int) time.Duration {
+		d := min
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+func (s *Supervisor) startKid(i int) {
+	k := &Gcx{}
+	k.SetParent(&s.Gcx, ChildIgnore)
+	k.SetGroup(&s.grp)
+	spec := s.Children[i]
+	k.GoNamed(spec.Name, func() error { return spec.Start(k) })
+	s.running[i] = k
+}
+
+func (s *Supervisor) kidIndex(k *Gcx) int {
+	for i, kk := range s.running {
+		if kk == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// stopKids kills the running children with indexes in [from, len),
+// waits for them to terminate, and returns true for each of them in
+// the slice it returns. Their group notifications are left for the
+// main loop, which ignores them.
+func (s *Supervisor) stopKids(from int) []bool {
+	stopped := make([]bool, len(s.running))
+	for i := from; i < len(s.running); i++ {
+		if s.running[i] != nil {
+			s.running[i].Kill()
+			stopped[i] = true
+		}
+	}
+	for i := from; i < len(s.running); i++ {
+		if stopped[i] {
+			s.running[i].Wait()
+			s.running[i] = nil
+		}
+	}
+	return stopped
+}
+
+// stopAll kills all children and waits for them to terminate.
+func (s *Supervisor) stopAll() {
+	for _, k := range s.running {
+		if k != nil {
+			k.Kill()
+		}
+	}
+	for s.grp.Count() > 0 {
+		s.grp.Wait()
+	}
+}
+
+// limit records a restart caused by failure f, and returns true if
+// the restart intensity limit has been exceeded.
+func (s *Supervisor) limit(f ChildFailure) bool {
+	in, per := s.Intensity, s.Period
+	if in == 0 && per == 0 {
+		in, per = DefaultIntensity, DefaultPeriod
+	}
+	rs := s.restarts[:0]
+	for _, r := range s.restarts {
+		if f.Time.Sub(r.Time) < per {
+			rs = append(rs, r)
+		}
+	}
+	s.restarts = append(rs, f)
+	return len(s.restarts) > in
+}
+
+func (s *Supervisor) run() error {
+	s.running = make([]*Gcx, len(s.Children))
+	for i := range s.Children {
+		s.startKid(i)
+	}
+	for {
+		k, xs := s.grp.Poll()
+		if k == nil {
+			select {
+			case <-s.ChKill():
+				s.stopAll()
+				return ErrKilled
+			case <-s.grp.ChReady():
+			}
+			continue
+		}
+		i := s.kidIndex(k)
+		if i < 0 {
+			continue
+		}
+		s.running[i] = nil
+		spec := s.Children[i]
+		if spec.Restart == Temporary ||
+			spec.Restart == Transient && (xs == nil || xs == ErrKilled) {
+			continue
+		}
+
+		f := ChildFailure{Name: spec.Name, Time: time.Now(), Err: xs}
+		if s.limit(f) {
+			s.stopAll()
+			fs := make([]ChildFailure, len(s.restarts))
+			copy(fs, s.restarts)
+			return &SupervisorError{Failures: fs}
+		}
+
+		var stopped []bool
+		switch s.Strategy {
+		case OneForAll:
+			stopped = s.stopKids(0)
+		case RestForOne:
+			stopped = s.stopKids(i + 1)
+		default:
+			stopped = make([]bool, len(s.running))
+		}
+		stopped[i] = true
+
+		if s.Backoff != nil {
+			tm := time.NewTimer(s.Backoff(len(s.restarts)))
+			select {
+			case <-s.ChKill():
+				tm.Stop()
+				s.stopAll()
+				return ErrKilled
+			case <-tm.C:
+			}
+		}
+
+		for j := range s.running {
+			if stopped[j] &&
+				(j == i || s.Children[j].Restart != Temporary) {
+				s.startKid(j)
+			}
+		}
+	}
+}
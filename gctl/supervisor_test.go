@@ -0,0 +1,124 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+type startCounter struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (sc *startCounter) get(name string) int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.n[name]
+}
+
+// spec returns a child spec that fails (after a short delay) the
+// first nfail times it is started, and then waits to be killed.
+func (sc *startCounter) spec(name string, r Restart, nfail int) ChildSpec {
+	return ChildSpec{
+		Name:    name,
+		Restart: r,
+		Start: func(c *Gcx) error {
+			sc.mu.Lock()
+			if sc.n == nil {
+				sc.n = make(map[string]int)
+			}
+			sc.n[name]++
+			n := sc.n[name]
+			sc.mu.Unlock()
+			if n <= nfail {
+				time.Sleep(10 * time.Millisecond)
+				return errors.New(name + " failed")
+			}
+			<-c.ChKill()
+			return ErrKilled
+		},
+	}
+}
+
+func TestSupervisorStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		st   Strategy
+		want [3]int
+	}{
+		{OneForOne, [3]int{1, 2, 1}},
+		{OneForAll, [3]int{2, 2, 1}},
+		{RestForOne, [3]int{1, 2, 1}},
+	} {
+		var sc startCounter
+		s := &Supervisor{
+			Strategy: tc.st,
+			Children: []ChildSpec{
+				sc.spec("a", Permanent, 0),
+				sc.spec("b", Permanent, 1),
+				sc.spec("c", Temporary, 0),
+			},
+		}
+		s.Start()
+		time.Sleep(100 * time.Millisecond)
+		if e := s.KillWait(); e != ErrKilled {
+			t.Fatalf("strategy %d: KillWait: %v", tc.st, e)
+		}
+		for i, name := range []string{"a", "b", "c"} {
+			if n := sc.get(name); n != tc.want[i] {
+				t.Fatalf("strategy %d: %s started %d times",
+					tc.st, name, n)
+			}
+		}
+	}
+}
+
+func TestSupervisorRestForOne(t *testing.T) {
+	var sc startCounter
+	s := &Supervisor{
+		Strategy: RestForOne,
+		Children: []ChildSpec{
+			sc.spec("a", Permanent, 0),
+			sc.spec("b", Transient, 1),
+			sc.spec("c", Transient, 0),
+		},
+	}
+	s.Start()
+	time.Sleep(100 * time.Millisecond)
+	s.KillWait()
+	if na, nb, nc := sc.get("a"), sc.get("b"), sc.get("c"); na != 1 || nb != 2 || nc != 2 {
+		t.Fatalf("started a: %d, b: %d, c: %d", na, nb, nc)
+	}
+}
+
+func TestSupervisorGiveUp(t *testing.T) {
+	var sc startCounter
+	s := &Supervisor{
+		Intensity: 2,
+		Period:    time.Second,
+		Backoff:   ExpBackoff(time.Millisecond, 4*time.Millisecond),
+		Children: []ChildSpec{
+			sc.spec("a", Permanent, 0),
+			sc.spec("b", Permanent, 100),
+		},
+	}
+	s.Start()
+	e := s.Wait()
+	se, ok := e.(*SupervisorError)
+	if !ok {
+		t.Fatalf("Wait: %v", e)
+	}
+	if len(se.Failures) != 3 || se.Failures[0].Name != "b" {
+		t.Fatalf("Bad failures: %v", se)
+	}
+	if n := sc.get("b"); n != 3 {
+		t.Fatalf("b started %d times", n)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+// ChildPolicy determines how the failure of a child context affects
+// its parent. A child context is considered to have failed if its
+// exit status is non-nil and not ErrKilled. See Gcx.SetParent.
+type ChildPolicy int
+
+// Child failure policies
+const (
+	// ChildIgnore: The child's exit status is discarded.
+	ChildIgnore ChildPolicy = iota
+	// ChildReport: The child's exit status becomes the parent's
+	// exit status (if the parent has not already failed), but
+	// the parent is not killed.
+	ChildReport
+	// ChildKill: The child's exit status becomes the parent's
+	// exit status (if the parent has not already failed), and the
+	// parent (along with all its other children) is killed.
+	ChildKill
+)
+
+// SetParent makes gcx c a child of context p. Like Gcx.SetGroup, it
+// must be called before c is started (before the first Gcx.Go
+// call). If SetParent is called for an already active gcx, it
+// panics. A gcx can have only one parent (or no parent at all).
+//
+// When c is started, parent p must be active (running); if it is
+// not, Gcx.Go panics. From then on, and until c terminates, c is
+// accounted as if it were a goroutine of p: p does not terminate
+// (and Wait on p does not return) until c, and all other children
+// of p, have terminated. Killing p kills c (and, recursively, c's
+// own children). If c is started while p is already killed, c starts
+// killed. Likewise, draining p (see Gcx.Drain) drains c. If c fails,
+// policy pol determines what happens to p (see ChildPolicy).
+func (c *Gcx) SetParent(p *Gcx, pol ChildPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill != nil {
+		panic("Gcx.SetParent: Gcx context not empty")
+	}
+	c.parent = p
+	c.policy = pol
+}
+
+// adopt registers child context k with its parent c. It is called
+// when k starts, with k.mu held. If c is killed, k is killed as well,
+// and the work returned must be done after k.mu is released. If c is
+// draining, k starts draining.
+func (c *Gcx) adopt(k *Gcx) kwork {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kill == nil || c.ngort == -1 {
+		panic("Gcx.Go: parent Gcx is not a running context")
+	}
+	if c.kids == nil {
+		c.kids = make(map[*Gcx]struct{})
+	}
+	c.kids[k] = struct{}{}
+	c.ngort++
+	k.pid = c.id
+	if c.signaled {
+		return k.signal(ErrParentKilled)
+	}
+	if c.draining {
+		k.startDrain()
+	}
+	return kwork{}
+}
+
+// release unregisters child context k from its parent c. It is
+// called when k terminates, with k.mu held.
+func (c *Gcx) release(k *Gcx) {
+	c.mu.Lock()
+	delete(c.kids, k)
+	c.mu.Unlock()
+}
+
+// orphan is called after child context k of c has terminated with
+// exit status xs. Context k is dead and must not be accessed; pol is
+// the policy k was started with.
+func (c *Gcx) orphan(xs error, pol ChildPolicy) {
+	c.mu.Lock()
+	switch {
+	case xs == ErrKilled || pol == ChildIgnore:
+		xs = nil
+	case xs != nil && pol == ChildReport:
+		c.record(xs)
+		xs = nil
+	}
+	c.mu.Unlock()
+	c.exit(nil, xs, nil)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2015, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package gctl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/errors"
+)
+
+func waitKill(c *Gcx) func() error {
+	return func() error {
+		<-c.ChKill()
+		return ErrKilled
+	}
+}
+
+func TestTreeKill(t *testing.T) {
+	var p, c1, c2, gc Gcx
+	p.Go(waitKill(&p))
+	c1.SetParent(&p, ChildKill)
+	c1.Go(waitKill(&c1))
+	c2.SetParent(&p, ChildKill)
+	c2.Go(waitKill(&c2))
+	gc.SetParent(&c2, ChildKill)
+	gc.Go(waitKill(&gc))
+
+	if e := p.KillWait(); e != ErrKilled {
+		t.Fatalf("p.KillWait: %v", e)
+	}
+	for i, c := range []*Gcx{&c1, &c2, &gc} {
+		select {
+		case <-c.dead:
+		default:
+			t.Fatalf("child %d not dead after parent Wait", i)
+		}
+	}
+}
+
+func TestTreeWaitChildren(t *testing.T) {
+	var p, c Gcx
+	done := false
+	p.Go(func() error { return nil })
+	c.SetParent(&p, ChildKill)
+	c.Go(func() error {
+		time.Sleep(50 * time.Millisecond)
+		done = true
+		return nil
+	})
+	if e := p.Wait(); e != nil {
+		t.Fatalf("p.Wait: %v", e)
+	}
+	if !done {
+		t.Fatal("parent Wait returned before child")
+	}
+}
+
+func TestTreePolicy(t *testing.T) {
+	errFail := errors.New("child failed")
+	for _, tc := range []struct {
+		pol    ChildPolicy
+		xs     error
+		killed bool
+	}{
+		{ChildIgnore, nil, false},
+		{ChildReport, errFail, false},
+		{ChildKill, errFail, true},
+	} {
+		var p, c Gcx
+		p.Go(func() error {
+			select {
+			case <-p.ChKill():
+				return ErrKilled
+			case <-time.After(100 * time.Millisecond):
+				return nil
+			}
+		})
+		c.SetParent(&p, tc.pol)
+		c.Go(func() error { return errFail })
+		if e := p.Wait(); e != tc.xs {
+			t.Fatalf("policy %d: p.Wait: %v", tc.pol, e)
+		}
+		select {
+		case <-p.kill:
+			if !tc.killed {
+				t.Fatalf("policy %d: parent killed", tc.pol)
+			}
+		default:
+			if tc.killed {
+				t.Fatalf("policy %d: parent not killed", tc.pol)
+			}
+		}
+	}
+}
+
+func TestTreeKilledParent(t *testing.T) {
+	var p, c Gcx
+	p.Go(waitKill(&p))
+	p.Kill()
+	c.SetParent(&p, ChildKill)
+	c.Go(waitKill(&c))
+	if e := c.Wait(); e != ErrKilled {
+		t.Fatalf("c.Wait: %v", e)
+	}
+	p.Wait()
+
+	func() {
+		defer func() {
+			x := recover()
+			s, ok := x.(string)
+			if !ok || !strings.HasPrefix(s, "Gcx.Go") {
+				panic(x)
+			}
+		}()
+		var c Gcx
+		c.SetParent(&p, ChildKill)
+		c.Go(func() error { return nil })
+		t.Fatal("Gcx.Go: No panic for dead parent")
+	}()
+}